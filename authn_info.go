@@ -0,0 +1,141 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// AuthnInfoHeader is the header through which services behind the Nuvla API authenticate requests on behalf of
+// a user, without session
+const AuthnInfoHeader = "nuvla-authn-info"
+
+// maxRedirects is the redirect limit of http.Client when no CheckRedirect is set
+const maxRedirects = 10
+
+// AuthnInfo is the identity sent in the nuvla-authn-info header
+type AuthnInfo struct {
+	// UserID is the user the request is made for, e.g. "user/<uuid>"
+	UserID string
+	// ActiveClaim is the identity the user acts as, e.g. one of its groups. Defaults to UserID.
+	ActiveClaim string
+	// Claims are the additional identities of the user, e.g. its groups and roles
+	Claims []string
+}
+
+// NewAuthnInfo creates the identity of userId acting as itself, with the given claims
+func NewAuthnInfo(userId string, claims ...string) *AuthnInfo {
+	return &AuthnInfo{
+		UserID:      userId,
+		ActiveClaim: userId,
+		Claims:      claims,
+	}
+}
+
+// WithActiveClaim returns a copy of the identity acting as claim, e.g. "group/my-team". A nil identity is copied
+// as an empty one.
+func (a *AuthnInfo) WithActiveClaim(claim string) *AuthnInfo {
+	var c AuthnInfo
+	if a != nil {
+		c = *a
+		c.Claims = append([]string{}, a.Claims...)
+	}
+	c.ActiveClaim = claim
+	return &c
+}
+
+// String serialises the identity in the header format: the user id, the active claim and the claims, separated
+// by spaces
+func (a *AuthnInfo) String() string {
+	if a == nil || a.UserID == "" {
+		return ""
+	}
+	activeClaim := a.ActiveClaim
+	if activeClaim == "" {
+		activeClaim = a.UserID
+	}
+	parts := append([]string{a.UserID, activeClaim}, a.Claims...)
+	return strings.Join(parts, " ")
+}
+
+// ParseAuthnInfo parses a nuvla-authn-info header value
+func ParseAuthnInfo(header string) (*AuthnInfo, error) {
+	fields := strings.Fields(header)
+	if len(fields) == 0 {
+		return nil, errors.New("empty authn info")
+	}
+	a := &AuthnInfo{UserID: fields[0], ActiveClaim: fields[0]}
+	if len(fields) > 1 {
+		a.ActiveClaim = fields[1]
+	}
+	if len(fields) > 2 {
+		a.Claims = fields[2:]
+	}
+	return a, nil
+}
+
+type authnInfoContextKey struct{}
+
+// ContextWithAuthnInfo returns a context making the requests on behalf of info, overriding the identity configured
+// in the session
+func ContextWithAuthnInfo(ctx context.Context, info *AuthnInfo) context.Context {
+	return contextWithAuthnHeader(ctx, info.String())
+}
+
+// AuthnInfoFromContext returns the identity set by ContextWithAuthnInfo, if any
+func AuthnInfoFromContext(ctx context.Context) (*AuthnInfo, bool) {
+	header, ok := ctx.Value(authnInfoContextKey{}).(string)
+	if !ok || header == "" {
+		return nil, false
+	}
+	info, err := ParseAuthnInfo(header)
+	return info, err == nil
+}
+
+func contextWithAuthnHeader(ctx context.Context, header string) context.Context {
+	return context.WithValue(ctx, authnInfoContextKey{}, header)
+}
+
+// authnHeaderFor returns the nuvla-authn-info header value for the request: the one of the context if set,
+// otherwise the one of the session
+func (s *NuvlaSession) authnHeaderFor(req *http.Request) string {
+	if header, ok := req.Context().Value(authnInfoContextKey{}).(string); ok && header != "" {
+		return header
+	}
+	return s.authnHeader
+}
+
+// isEndpoint returns true if u points to the Nuvla endpoint of the session. The identity header is only ever sent
+// to it.
+func (s *NuvlaSession) isEndpoint(u *url.URL) bool {
+	return s.endpointURL != nil && strings.EqualFold(u.Scheme, s.endpointURL.Scheme) &&
+		hostPort(u) == hostPort(s.endpointURL)
+}
+
+// hostPort returns the host of u with its port, the default one of the scheme if not explicit
+func hostPort(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(strings.ToLower(u.Scheme))
+	}
+	return strings.ToLower(u.Hostname()) + ":" + port
+}
+
+// checkRedirect removes the identity header from redirects leaving the endpoint, since http.Client copies the
+// custom headers of the original request. It then applies the redirect policy of the client, if any.
+func (s *NuvlaSession) checkRedirect(policy func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if !s.isEndpoint(req.URL) {
+			req.Header.Del(AuthnInfoHeader)
+		}
+		if policy != nil {
+			return policy(req, via)
+		}
+		if len(via) >= maxRedirects {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,135 @@
+package api_client_go
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+// headerRecorder records the nuvla-authn-info headers received by a test server
+type headerRecorder struct {
+	mu      sync.Mutex
+	headers []string
+}
+
+func (h *headerRecorder) record(r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.headers = append(h.headers, r.Header.Get(AuthnInfoHeader))
+}
+
+func (h *headerRecorder) received() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string{}, h.headers...)
+}
+
+func TestAuthnInfo_String(t *testing.T) {
+	info := NewAuthnInfo("user/1", "group/nuvla-user", "group/nuvla-anon")
+	if got, want := info.String(), "user/1 user/1 group/nuvla-user group/nuvla-anon"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	group := info.WithActiveClaim("group/team")
+	if got, want := group.String(), "user/1 group/team group/nuvla-user group/nuvla-anon"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	parsed, err := ParseAuthnInfo(group.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.String() != group.String() {
+		t.Errorf("parsed %q, want %q", parsed.String(), group.String())
+	}
+}
+
+func TestAuthnInfo_WithActiveClaim(t *testing.T) {
+	var none *AuthnInfo
+	info := none.WithActiveClaim("group/team")
+	if info == nil || info.ActiveClaim != "group/team" || info.UserID != "" {
+		t.Errorf("expected an empty identity with the active claim, got %+v", info)
+	}
+
+	user := NewAuthnInfo("user/1", "group/nuvla-user")
+	group := user.WithActiveClaim("group/team")
+	group.Claims[0] = "group/changed"
+	if user.ActiveClaim != "user/1" || user.Claims[0] != "group/nuvla-user" {
+		t.Errorf("expected the original identity to be unchanged, got %+v", user)
+	}
+}
+
+func TestAuthnInfo_HeaderNeverLeaksToOtherHosts(t *testing.T) {
+	var other headerRecorder
+	otherSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		other.record(r)
+		_, _ = w.Write([]byte(`{"id": "other/1"}`))
+	}))
+	defer otherSrv.Close()
+
+	var nuvla headerRecorder
+	nuvlaSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nuvla.record(r)
+		if r.URL.Path == "/api/redirect/1" {
+			http.Redirect(w, r, otherSrv.URL+"/api/other/1", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": "nuvlabox/1"}`))
+	}))
+	defer nuvlaSrv.Close()
+
+	session := NewAuthnInfo("user/session", "group/nuvla-user")
+	call := NewAuthnInfo("user/call", "group/nuvla-user")
+	fromCtx := NewAuthnInfo("user/context", "group/nuvla-user")
+
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(nuvlaSrv.URL), WithoutPersistCookie, WithAuthnInfo(session))
+	ctx := context.Background()
+
+	if _, err := c.Get(ctx, "nuvlabox/1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ctx, "nuvlabox/1", nil, WithCallAuthnInfo(call)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Get(ContextWithAuthnInfo(ctx, fromCtx), "nuvlabox/1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Redirect to another host
+	if _, err := c.Get(ctx, "redirect/1", nil, WithCallAuthnInfo(call)); err != nil {
+		t.Fatal(err)
+	}
+	// Request sent directly to another host through the session
+	res, err := c.Request(ContextWithAuthnInfo(ctx, fromCtx), &types.RequestOpts{
+		Method:   http.MethodGet,
+		Endpoint: otherSrv.URL + "/api/other/1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = res.Body.Close()
+
+	want := []string{session.String(), call.String(), fromCtx.String(), call.String()}
+	got := nuvla.received()
+	if len(got) != len(want) {
+		t.Fatalf("nuvla received %d requests, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("request %d: got header %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	for i, h := range other.received() {
+		if h != "" {
+			t.Errorf("request %d to other host leaked header %q", i, h)
+		}
+	}
+	if n := len(other.received()); n != 2 {
+		t.Errorf("other host received %d requests, want 2", n)
+	}
+}
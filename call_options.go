@@ -0,0 +1,47 @@
+package api_client_go
+
+import (
+	"github.com/nuvla/api-client-go/types"
+	"time"
+)
+
+// CallOptions tune a single API call, e.g. Get or Operation
+type CallOptions struct {
+	// Timeout bounds the whole call, retries and re-authentication included. It replaces the session timeout.
+	Timeout time.Duration
+	// AuthnInfo makes the call on behalf of another identity than the one configured in the session
+	AuthnInfo *AuthnInfo
+}
+
+// CallOption sets one of the CallOptions
+type CallOption func(*CallOptions)
+
+// WithCallTimeout bounds the call to timeout, e.g. a short one for heartbeats or a long one for large searches
+func WithCallTimeout(timeout time.Duration) CallOption {
+	return func(opts *CallOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+// WithCallAuthnInfo makes the call on behalf of info through the nuvla-authn-info header
+func WithCallAuthnInfo(info *AuthnInfo) CallOption {
+	return func(opts *CallOptions) {
+		opts.AuthnInfo = info
+	}
+}
+
+func newCallOptions(opts []CallOption) *CallOptions {
+	o := &CallOptions{}
+	for _, fn := range opts {
+		fn(o)
+	}
+	return o
+}
+
+// applyCallOptions sets the call options on the request inputs
+func applyCallOptions(reqInput *types.RequestOpts, opts []CallOption) *types.RequestOpts {
+	o := newCallOptions(opts)
+	reqInput.Timeout = o.Timeout
+	reqInput.AuthnHeader = o.AuthnInfo.String()
+	return reqInput
+}
@@ -0,0 +1,60 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithCallTimeout(t *testing.T) {
+	const handlerDelay = 200 * time.Millisecond
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(handlerDelay):
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": "nuvlabox/1"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name           string
+		sessionTimeout time.Duration
+		callTimeout    time.Duration
+		wantErr        bool
+	}{
+		{name: "call deadline cuts the handler short", sessionTimeout: time.Minute, callTimeout: 20 * time.Millisecond, wantErr: true},
+		{name: "call timeout replaces a shorter session timeout", sessionTimeout: 20 * time.Millisecond, callTimeout: time.Minute},
+		{name: "session timeout without call timeout", sessionTimeout: 20 * time.Millisecond, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie, WithTimeout(tt.sessionTimeout))
+			var opts []CallOption
+			if tt.callTimeout != 0 {
+				opts = append(opts, WithCallTimeout(tt.callTimeout))
+			}
+
+			start := time.Now()
+			_, err := c.Get(context.Background(), "nuvlabox/1", nil, opts...)
+			elapsed := time.Since(start)
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("expected the deadline to be exceeded, got %v", err)
+			}
+			if elapsed >= handlerDelay {
+				t.Errorf("expected the call to be cut short, took %s", elapsed)
+			}
+		})
+	}
+}
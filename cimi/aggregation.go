@@ -0,0 +1,102 @@
+package cimi
+
+import "strings"
+
+// AggregationType is one of the aggregation functions supported by Nuvla
+type AggregationType string
+
+const (
+	AggregationTerms       AggregationType = "terms"
+	AggregationValueCount  AggregationType = "value_count"
+	AggregationMin         AggregationType = "min"
+	AggregationMax         AggregationType = "max"
+	AggregationAvg         AggregationType = "avg"
+	AggregationSum         AggregationType = "sum"
+	AggregationCardinality AggregationType = "cardinality"
+)
+
+// AggregationKey returns the key under which Nuvla returns the result of an aggregation, e.g. "terms:state"
+func AggregationKey(aggType AggregationType, attribute string) string {
+	return string(aggType) + ":" + attribute
+}
+
+// Aggregation is a list of CIMI aggregations computed on the resources matching a search.
+// The zero value requests no aggregation.
+type Aggregation struct {
+	exprs []string
+}
+
+// Terms returns an aggregation counting the resources per distinct value of attribute
+func Terms(attribute string) Aggregation {
+	return Aggregation{}.Terms(attribute)
+}
+
+// ValueCount returns an aggregation counting the values of attribute
+func ValueCount(attribute string) Aggregation {
+	return Aggregation{}.ValueCount(attribute)
+}
+
+// Min returns an aggregation computing the minimum of attribute
+func Min(attribute string) Aggregation {
+	return Aggregation{}.Min(attribute)
+}
+
+// Max returns an aggregation computing the maximum of attribute
+func Max(attribute string) Aggregation {
+	return Aggregation{}.Max(attribute)
+}
+
+// Avg returns an aggregation computing the average of attribute
+func Avg(attribute string) Aggregation {
+	return Aggregation{}.Avg(attribute)
+}
+
+// Sum returns an aggregation computing the sum of attribute
+func Sum(attribute string) Aggregation {
+	return Aggregation{}.Sum(attribute)
+}
+
+// Cardinality returns an aggregation computing the approximate number of distinct values of attribute
+func Cardinality(attribute string) Aggregation {
+	return Aggregation{}.Cardinality(attribute)
+}
+
+// With adds an aggregation of the given type on attribute
+func (a Aggregation) With(aggType AggregationType, attribute string) Aggregation {
+	exprs := make([]string, len(a.exprs), len(a.exprs)+1)
+	copy(exprs, a.exprs)
+	return Aggregation{exprs: append(exprs, AggregationKey(aggType, attribute))}
+}
+
+func (a Aggregation) Terms(attribute string) Aggregation {
+	return a.With(AggregationTerms, attribute)
+}
+
+func (a Aggregation) ValueCount(attribute string) Aggregation {
+	return a.With(AggregationValueCount, attribute)
+}
+
+func (a Aggregation) Min(attribute string) Aggregation {
+	return a.With(AggregationMin, attribute)
+}
+
+func (a Aggregation) Max(attribute string) Aggregation {
+	return a.With(AggregationMax, attribute)
+}
+
+func (a Aggregation) Avg(attribute string) Aggregation {
+	return a.With(AggregationAvg, attribute)
+}
+
+func (a Aggregation) Sum(attribute string) Aggregation {
+	return a.With(AggregationSum, attribute)
+}
+
+func (a Aggregation) Cardinality(attribute string) Aggregation {
+	return a.With(AggregationCardinality, attribute)
+}
+
+// String returns the expression to be used in SearchOptions.Aggregation
+func (a Aggregation) String() string {
+	return strings.Join(a.exprs, ",")
+}
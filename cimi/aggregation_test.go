@@ -0,0 +1,50 @@
+package cimi
+
+import "testing"
+
+func TestAggregation(t *testing.T) {
+	tests := []struct {
+		name        string
+		aggregation Aggregation
+		want        string
+	}{
+		{"zero value", Aggregation{}, ""},
+		{"terms", Terms("state"), "terms:state"},
+		{"value count", ValueCount("id"), "value_count:id"},
+		{"min", Min("created"), "min:created"},
+		{"max", Max("created"), "max:created"},
+		{"avg", Avg("refresh-interval"), "avg:refresh-interval"},
+		{"sum", Sum("cpu"), "sum:cpu"},
+		{"cardinality", Cardinality("owner"), "cardinality:owner"},
+		{"method chaining", Terms("state").Avg("refresh-interval").Cardinality("owner"),
+			"terms:state,avg:refresh-interval,cardinality:owner"},
+		{"with", Aggregation{}.With(AggregationTerms, "online").With(AggregationMax, "updated"),
+			"terms:online,max:updated"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.aggregation.String(); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAggregation_Immutable(t *testing.T) {
+	base := Terms("state")
+	withAvg := base.Avg("cpu")
+	withMax := base.Max("cpu")
+
+	if base.String() != "terms:state" {
+		t.Errorf("expected the base aggregation to be unchanged, got %s", base)
+	}
+	if withAvg.String() != "terms:state,avg:cpu" || withMax.String() != "terms:state,max:cpu" {
+		t.Errorf("expected independent aggregations, got %s and %s", withAvg, withMax)
+	}
+}
+
+func TestAggregationKey(t *testing.T) {
+	if got := AggregationKey(AggregationTerms, "state"); got != "terms:state" {
+		t.Errorf("expected terms:state, got %s", got)
+	}
+}
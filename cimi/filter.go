@@ -0,0 +1,208 @@
+// Package cimi provides builders for the CIMI query parameters understood by the Nuvla API: filters, ordering and
+// aggregations. The builders take care of quoting and escaping values so that user input cannot alter the
+// structure of the expression.
+package cimi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeFormat is the format used to serialise dates in filters
+const TimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Filter is a CIMI filter expression. The zero value is an empty filter, which matches every resource.
+type Filter struct {
+	expr string
+	// compound is true for top level and/or expressions, which need parentheses when nested
+	compound bool
+}
+
+// String returns the expression to be used in SearchOptions.Filter
+func (f Filter) String() string {
+	return f.expr
+}
+
+// IsEmpty returns true if the filter has no expression
+func (f Filter) IsEmpty() bool {
+	return f.expr == ""
+}
+
+// Raw wraps an already built filter expression. The expression is not escaped, so it must never contain
+// untrusted input.
+func Raw(expr string) Filter {
+	return Filter{expr: expr, compound: true}
+}
+
+// Quote returns value as a single-quoted CIMI string, escaping backslashes and quotes
+func Quote(value string) string {
+	escaped := strings.ReplaceAll(value, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `'`, `\'`)
+	return "'" + escaped + "'"
+}
+
+// formatValue serialises a Go value as a CIMI literal
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return Quote(v)
+	case time.Time:
+		return Quote(v.UTC().Format(TimeFormat))
+	case fmt.Stringer:
+		return Quote(v.String())
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%d", v)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return Quote(fmt.Sprintf("%v", v))
+	}
+}
+
+func comparison(attribute, op string, value interface{}) Filter {
+	return Filter{expr: attribute + op + formatValue(value)}
+}
+
+// Eq matches resources where attribute equals value
+func Eq(attribute string, value interface{}) Filter {
+	return comparison(attribute, "=", value)
+}
+
+// Ne matches resources where attribute is different from value
+func Ne(attribute string, value interface{}) Filter {
+	return comparison(attribute, "!=", value)
+}
+
+// Lt matches resources where attribute is lower than value
+func Lt(attribute string, value interface{}) Filter {
+	return comparison(attribute, "<", value)
+}
+
+// Le matches resources where attribute is lower or equal than value
+func Le(attribute string, value interface{}) Filter {
+	return comparison(attribute, "<=", value)
+}
+
+// Gt matches resources where attribute is greater than value
+func Gt(attribute string, value interface{}) Filter {
+	return comparison(attribute, ">", value)
+}
+
+// Ge matches resources where attribute is greater or equal than value
+func Ge(attribute string, value interface{}) Filter {
+	return comparison(attribute, ">=", value)
+}
+
+// Prefix matches resources where the attribute starts with prefix
+func Prefix(attribute, prefix string) Filter {
+	return comparison(attribute, "^=", prefix)
+}
+
+// IsNull matches resources where the attribute is not set
+func IsNull(attribute string) Filter {
+	return comparison(attribute, "=", nil)
+}
+
+// NotNull matches resources where the attribute is set
+func NotNull(attribute string) Filter {
+	return comparison(attribute, "!=", nil)
+}
+
+// Before matches resources where the date attribute is before t
+func Before(attribute string, t time.Time) Filter {
+	return Lt(attribute, t)
+}
+
+// After matches resources where the date attribute is after t
+func After(attribute string, t time.Time) Filter {
+	return Gt(attribute, t)
+}
+
+// Since matches resources where the date attribute is within the last d, relative to the server time
+func Since(attribute string, d time.Duration) Filter {
+	return Filter{expr: fmt.Sprintf("%s>'now-%ds'", attribute, int64(d.Seconds()))}
+}
+
+// None matches no resource: every resource has an id. It is not empty, so And and Or keep it.
+func None() Filter {
+	return IsNull("id")
+}
+
+// In matches resources where attribute equals any of the values. An empty list matches no resource.
+func In[V any](attribute string, values ...V) Filter {
+	if len(values) == 0 {
+		return None()
+	}
+	filters := make([]Filter, 0, len(values))
+	for _, v := range values {
+		filters = append(filters, Eq(attribute, v))
+	}
+	return Or(filters...)
+}
+
+func group(f Filter) string {
+	if f.compound {
+		return "(" + f.expr + ")"
+	}
+	return f.expr
+}
+
+func join(op string, filters []Filter) Filter {
+	parts := make([]Filter, 0, len(filters))
+	for _, f := range filters {
+		if f.IsEmpty() {
+			continue
+		}
+		parts = append(parts, f)
+	}
+	switch len(parts) {
+	case 0:
+		return Filter{}
+	case 1:
+		return parts[0]
+	}
+
+	exprs := make([]string, 0, len(parts))
+	for _, f := range parts {
+		exprs = append(exprs, group(f))
+	}
+	return Filter{expr: strings.Join(exprs, " "+op+" "), compound: true}
+}
+
+// And matches resources matching all the filters. Empty filters are ignored.
+func And(filters ...Filter) Filter {
+	return join("and", filters)
+}
+
+// Or matches resources matching any of the filters. Empty filters are ignored.
+func Or(filters ...Filter) Filter {
+	return join("or", filters)
+}
+
+// And returns the conjunction of f and the other filters
+func (f Filter) And(filters ...Filter) Filter {
+	return And(append([]Filter{f}, filters...)...)
+}
+
+// Or returns the disjunction of f and the other filters
+func (f Filter) Or(filters ...Filter) Filter {
+	return Or(append([]Filter{f}, filters...)...)
+}
+
+// Group wraps the filter in parentheses
+func Group(f Filter) Filter {
+	if f.IsEmpty() {
+		return f
+	}
+	return Filter{expr: "(" + f.expr + ")"}
+}
@@ -0,0 +1,64 @@
+package cimi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", `''`},
+		{"nuvlabox/1", `'nuvlabox/1'`},
+		{"it's", `'it\'s'`},
+		{`back\slash`, `'back\\slash'`},
+		{`\'`, `'\\\''`},
+		{`x' or id!=null or name='y`, `'x\' or id!=null or name=\'y'`},
+	}
+	for _, tt := range tests {
+		if got := Quote(tt.in); got != tt.want {
+			t.Errorf("Quote(%q) = %s, expected %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	date := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		filter Filter
+		want   string
+	}{
+		{"eq string", Eq("state", "COMMISSIONED"), "state='COMMISSIONED'"},
+		{"eq escaped", Eq("name", "it's"), `name='it\'s'`},
+		{"number", Gt("cpu", 2), "cpu>2"},
+		{"float", Le("load", 0.5), "load<=0.5"},
+		{"bool", Eq("online", true), "online=true"},
+		{"null", IsNull("parent"), "parent=null"},
+		{"date", Before("created", date), "created<'2024-05-01T10:00:00.000Z'"},
+		{"since", Since("updated", time.Hour), "updated>'now-3600s'"},
+		{"and", And(Eq("a", 1), Eq("b", 2)), "a=1 and b=2"},
+		{"or nested in and", And(Eq("a", 1), Or(Eq("b", 2), Eq("c", 3))), "a=1 and (b=2 or c=3)"},
+		{"and nested in or", Or(And(Eq("a", 1), Eq("b", 2)), Eq("c", 3)), "(a=1 and b=2) or c=3"},
+		{"empty filters ignored", And(Filter{}, Eq("a", 1), Filter{}), "a=1"},
+		{"all empty", And(Filter{}, Filter{}), ""},
+		{"method chaining", Eq("a", 1).And(Eq("b", 2)).Or(Eq("c", 3)), "(a=1 and b=2) or c=3"},
+		{"group", Group(Eq("a", 1)), "(a=1)"},
+		{"group empty", Group(Filter{}), ""},
+		{"raw grouped", And(Raw("a=1 or b=2"), Eq("c", 3)), "(a=1 or b=2) and c=3"},
+		{"in", In("state", "NEW", "ACTIVATED"), "state='NEW' or state='ACTIVATED'"},
+		{"in single", In("state", "NEW"), "state='NEW'"},
+		{"in nested", And(Eq("a", 1), In("id", "x", "y")), "a=1 and (id='x' or id='y')"},
+		{"in empty", In[string]("id"), "id=null"},
+		{"in empty kept by and", And(Eq("state", "X"), In[string]("id")), "state='X' and id=null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.String(); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
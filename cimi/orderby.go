@@ -0,0 +1,48 @@
+package cimi
+
+import "strings"
+
+// Direction is the sort direction of an OrderBy field
+type Direction string
+
+const (
+	Ascending  Direction = "asc"
+	Descending Direction = "desc"
+)
+
+// OrderBy is a CIMI ordering over one or more attributes. The zero value keeps the server default ordering.
+type OrderBy struct {
+	fields []string
+}
+
+// Asc returns an ordering by attribute in ascending order
+func Asc(attribute string) OrderBy {
+	return OrderBy{}.Asc(attribute)
+}
+
+// Desc returns an ordering by attribute in descending order
+func Desc(attribute string) OrderBy {
+	return OrderBy{}.Desc(attribute)
+}
+
+// By returns an ordering by attribute in the given direction
+func (o OrderBy) By(attribute string, direction Direction) OrderBy {
+	fields := make([]string, len(o.fields), len(o.fields)+1)
+	copy(fields, o.fields)
+	return OrderBy{fields: append(fields, attribute+":"+string(direction))}
+}
+
+// Asc adds attribute in ascending order. It only applies to resources equal on the previous attributes.
+func (o OrderBy) Asc(attribute string) OrderBy {
+	return o.By(attribute, Ascending)
+}
+
+// Desc adds attribute in descending order. It only applies to resources equal on the previous attributes.
+func (o OrderBy) Desc(attribute string) OrderBy {
+	return o.By(attribute, Descending)
+}
+
+// String returns the expression to be used in SearchOptions.OrderBy
+func (o OrderBy) String() string {
+	return strings.Join(o.fields, ",")
+}
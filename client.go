@@ -2,36 +2,60 @@ package api_client_go
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/nuvla/api-client-go/clients/resources"
 	"github.com/nuvla/api-client-go/common"
 	"github.com/nuvla/api-client-go/types"
-	log "github.com/sirupsen/logrus"
 	"github.com/wI2L/jsondiff"
 	"io"
 	"net/http"
+	"sync"
 )
 
+// NuvlaClient is safe for concurrent use by multiple goroutines. Concurrent requests failing with 401 trigger a
+// single re-authentication, which the other requests wait for.
 type NuvlaClient struct {
 	// Session params
 	*NuvlaSession
 	SessionOpts SessionOptions
+	// Credentials of the last successful login. When the client is shared between goroutines, use
+	// CurrentCredentials and SetCredentials instead of accessing the field directly.
 	Credentials types.LogInParams
+
+	// authMu guards Credentials, credsFromProvider, authGeneration and authCall
+	authMu sync.Mutex
+	// credsFromProvider tells whether the Credentials were supplied by the CredentialProvider, in which case they
+	// are requested again from it on re-authentication
+	credsFromProvider bool
+	// authGeneration is incremented on every login and logout
+	authGeneration uint64
+	// authCall is the re-authentication in progress, if any
+	authCall *loginCall
+}
+
+// loginCall is a re-authentication shared by all the requests which need it at the same time
+type loginCall struct {
+	done chan struct{}
+	err  error
 }
 
+// NewNuvlaClient creates a client and, if credentials are provided or available from the CredentialProvider, logs
+// in. A failed login is only logged: use NewAuthenticatedNuvlaClient to get the error.
 func NewNuvlaClient(cred types.LogInParams, opts *SessionOptions) *NuvlaClient {
-	nc := &NuvlaClient{
-		NuvlaSession: NewNuvlaSession(opts),
-		SessionOpts:  *opts,
+	nc := newNuvlaClient(opts)
+
+	ctx := context.Background()
+	cred, fromProvider, err := nc.resolveCredentials(ctx, cred)
+	if err != nil {
+		nc.log.Debugf("No credentials available from provider: %s", err)
+		return nc
 	}
 
 	if !common.IsNilValueInterface(cred) {
-		log.Debug("Logging in with api keys...")
-		if err := nc.login(cred); err != nil {
-			log.Errorf("Error logging in with api keys: %s.", err)
-		} else {
-			nc.Credentials = cred
+		nc.log.Debug("Logging in with api keys...")
+		if err := nc.loginWith(ctx, cred, fromProvider); err != nil {
+			nc.log.Errorf("Error logging in with api keys: %s.", err)
 		}
 	}
 	return nc
@@ -46,38 +70,214 @@ func NewNuvlaClientFromOpts(cred types.LogInParams, opts ...SessionOptFunc) *Nuv
 	return NewNuvlaClient(cred, sessionOpts)
 }
 
-func (nc *NuvlaClient) LoginApiKeys(key string, secret string) error {
-	logInParams := types.NewApiKeyLogInParams(key, secret)
-	err := nc.login(logInParams)
+// NewAuthenticatedNuvlaClient creates a client and logs in with cred, or with the credentials of the
+// CredentialProvider if cred is nil. It returns an error if there are no credentials or the login fails.
+func NewAuthenticatedNuvlaClient(ctx context.Context, cred types.LogInParams, opts ...SessionOptFunc) (*NuvlaClient, error) {
+	sessionOpts := DefaultSessionOpts()
+	for _, fn := range opts {
+		fn(sessionOpts)
+	}
+	nc := newNuvlaClient(sessionOpts)
+
+	cred, fromProvider, err := nc.resolveCredentials(ctx, cred)
 	if err != nil {
-		log.Errorf("Error logging in with api keys: %s", err)
+		return nil, err
+	}
+	if common.IsNilValueInterface(cred) {
+		return nil, ErrNoCredentials
+	}
+	if err := nc.loginWith(ctx, cred, fromProvider); err != nil {
+		return nil, err
+	}
+	return nc, nil
+}
+
+func newNuvlaClient(opts *SessionOptions) *NuvlaClient {
+	return &NuvlaClient{
+		NuvlaSession: NewNuvlaSession(opts),
+		SessionOpts:  *opts,
+	}
+}
+
+// resolveCredentials returns cred, or the credentials of the CredentialProvider if cred is nil. fromProvider tells
+// which one was returned.
+func (nc *NuvlaClient) resolveCredentials(ctx context.Context, cred types.LogInParams) (types.LogInParams, bool, error) {
+	if !common.IsNilValueInterface(cred) || nc.SessionOpts.CredentialProvider == nil {
+		return cred, false, nil
+	}
+	params, err := nc.SessionOpts.CredentialProvider.Credentials(ctx)
+	return params, true, err
+}
+
+// loginWith logs in with cred and records whether they were supplied by the CredentialProvider
+func (nc *NuvlaClient) loginWith(ctx context.Context, cred types.LogInParams, fromProvider bool) error {
+	if err := nc.Login(ctx, cred); err != nil {
+		return err
+	}
+	nc.authMu.Lock()
+	nc.credsFromProvider = fromProvider
+	nc.authMu.Unlock()
+	return nil
+}
+
+// Login logs in with the given parameters and keeps them to re-authenticate. If the account requires two-factor
+// authentication, the code is requested from the TwoFactorHandler. Without handler, the returned error is a
+// *TwoFactorChallenge to complete with CompleteTwoFactor.
+func (nc *NuvlaClient) Login(ctx context.Context, logInParams types.LogInParams) error {
+	if err := nc.login(ctx, logInParams); err != nil {
+		var challenge *TwoFactorChallenge
+		if errors.As(err, &challenge) {
+			return nc.completeTwoFactorWithHandler(ctx, challenge)
+		}
 		return err
 	}
 	// Save login params if successful and Credentials are different from the current ones
-	nc.Credentials = logInParams
+	nc.loggedIn(logInParams)
 	return nil
 }
 
-func (nc *NuvlaClient) LoginUser(username string, password string) error {
-	logInParams := types.NewUserLogInParams(username, password)
-	err := nc.login(logInParams)
+func (nc *NuvlaClient) LoginApiKeys(ctx context.Context, key string, secret string) error {
+	err := nc.Login(ctx, types.NewApiKeyLogInParams(key, secret))
 	if err != nil {
-		log.Errorf("Error logging in with user Credentials: %s", err)
+		nc.log.Errorf("Error logging in with api keys: %s", err)
 		return err
 	}
-	// Save login params if successful and Credentials are different from the current ones
-	nc.Credentials = logInParams
 	return nil
 }
 
-func (nc *NuvlaClient) Logout() error {
-	// Close connections and logout
+func (nc *NuvlaClient) LoginUser(ctx context.Context, username string, password string) error {
+	err := nc.Login(ctx, types.NewUserLogInParams(username, password))
+	if err != nil {
+		nc.log.Errorf("Error logging in with user Credentials: %s", err)
+		return err
+	}
+	return nil
+}
+
+// CurrentCredentials returns the credentials of the last successful login, or nil if there are none
+func (nc *NuvlaClient) CurrentCredentials() types.LogInParams {
+	nc.authMu.Lock()
+	defer nc.authMu.Unlock()
+	return nc.Credentials
+}
+
+// SetCredentials sets the credentials used to re-authenticate, without logging in. They take precedence over the
+// CredentialProvider.
+func (nc *NuvlaClient) SetCredentials(creds types.LogInParams) {
+	nc.authMu.Lock()
+	defer nc.authMu.Unlock()
+	nc.Credentials = creds
+	nc.credsFromProvider = false
+}
+
+// loggedIn records the credentials of a successful login, given explicitly unless loginWith says otherwise
+func (nc *NuvlaClient) loggedIn(creds types.LogInParams) {
+	nc.authMu.Lock()
+	defer nc.authMu.Unlock()
+	nc.Credentials = creds
+	nc.credsFromProvider = false
+	nc.authGeneration++
+}
 
-	return nc.logout()
+func (nc *NuvlaClient) currentAuthGeneration() uint64 {
+	nc.authMu.Lock()
+	defer nc.authMu.Unlock()
+	return nc.authGeneration
 }
 
+// Logout deletes the current session in the server, removes the local cookies, both in memory and in the cookie
+// file, and forgets the credentials. All the steps are executed even if one of them fails, and the errors are
+// returned together. The server is not contacted if the session cookies have already expired.
+func (nc *NuvlaClient) Logout(ctx context.Context) error {
+	var errs []error
+
+	if nc.NeedToLogin() {
+		// Looking the session up would log in again with ReAuthenticate, only to delete the new session
+		nc.log.Debugf("Session expired, nothing to delete in the server")
+	} else if err := nc.deleteCurrentSession(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	// Close connections and remove cookies
+	if err := nc.logout(); err != nil {
+		errs = append(errs, err)
+	}
+	nc.loggedIn(nil)
+
+	return errors.Join(errs...)
+}
+
+// deleteCurrentSession deletes the session of the client in the server, if the server still knows it
+func (nc *NuvlaClient) deleteCurrentSession(ctx context.Context) error {
+	session, err := nc.CurrentSession(ctx)
+	if errors.Is(err, types.ErrUnauthorized) {
+		nc.log.Debugf("No active session to delete")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error retrieving current session: %w", err)
+	}
+	if _, err := nc.Delete(ctx, session.Id); err != nil {
+		return fmt.Errorf("error deleting session %s: %w", session.Id, err)
+	}
+	return nil
+}
+
+// IsAuthenticated returns true if the client holds a valid session.
+//
+// Deprecated: use IsAuthenticatedContext, which can be cancelled while the session is confirmed with the server.
 func (nc *NuvlaClient) IsAuthenticated() bool {
-	return true
+	return nc.IsAuthenticatedContext(context.Background())
+}
+
+// IsAuthenticatedContext returns true if the client holds a valid session. The session cookies are checked first
+// and, if they have not expired, the session is confirmed with the server.
+func (nc *NuvlaClient) IsAuthenticatedContext(ctx context.Context) bool {
+	if nc.NeedToLogin() {
+		return false
+	}
+
+	session, err := nc.CurrentSession(ctx)
+	if err != nil {
+		nc.log.Debugf("Cannot retrieve current session: %s", err)
+		return false
+	}
+	return !session.IsExpired()
+}
+
+// CurrentSession returns the session resource of the authenticated user
+func (nc *NuvlaClient) CurrentSession(ctx context.Context) (*resources.SessionResource, error) {
+	collection, err := nc.Search(ctx, string(resources.SessionType), NewDefaultSearchOptions())
+	if err != nil {
+		return nil, err
+	}
+	if len(collection.Resources) == 0 {
+		return nil, fmt.Errorf("no active session: %w", types.ErrUnauthorized)
+	}
+
+	session := &resources.SessionResource{}
+	if err := resources.NewResourceFromMap(collection.Resources[0], session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// ListSessionTemplates returns the login methods available on the Nuvla instance. It does not require a session.
+func (nc *NuvlaClient) ListSessionTemplates(ctx context.Context) ([]*resources.SessionTemplateResource, error) {
+	collection, err := nc.Search(ctx, string(resources.SessionTemplateType), NewDefaultSearchOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	templates := make([]*resources.SessionTemplateResource, 0, len(collection.Resources))
+	for _, m := range collection.Resources {
+		template := &resources.SessionTemplateResource{}
+		if err := resources.NewResourceFromMap(m, template); err != nil {
+			return nil, err
+		}
+		templates = append(templates, template)
+	}
+	return templates, nil
 }
 
 func (nc *NuvlaClient) buildUriEndPoint(uriEndpoint string) string {
@@ -95,6 +295,19 @@ func (nc *NuvlaClient) needsAuthentication(statusCode int, url string) bool {
 }
 
 func (nc *NuvlaClient) cimiRequest(ctx context.Context, reqInput *types.RequestOpts) (*http.Response, error) {
+	// The call timeout covers the retries and the re-authentication, and lasts until the body is closed
+	ctx, cancel := withTimeout(ctx, reqInput.Timeout)
+	ctx, span := nc.startCallSpan(ctx, reqInput)
+	r, err := nc.doCimiRequest(ctx, reqInput, span)
+	endSpan(span, r, err)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return bindCancel(r, cancel), nil
+}
+
+func (nc *NuvlaClient) doCimiRequest(ctx context.Context, reqInput *types.RequestOpts, span Span) (*http.Response, error) {
 	// Setup default client headers for all requests
 	// TODO: Might be configurable from session
 	if reqInput.Headers == nil {
@@ -108,7 +321,8 @@ func (nc *NuvlaClient) cimiRequest(ctx context.Context, reqInput *types.RequestO
 		reqInput.Headers["bulk"] = "true"
 	}
 
-	r, err := nc.Request(ctx, reqInput)
+	authGeneration := nc.currentAuthGeneration()
+	r, err := nc.requestWithRetry(ctx, reqInput)
 	if err != nil {
 		if r != nil {
 			_ = r.Body.Close()
@@ -123,17 +337,32 @@ func (nc *NuvlaClient) cimiRequest(ctx context.Context, reqInput *types.RequestO
 			_ = r.Body.Close()
 			return nil, fmt.Errorf("error reading response body: %s", err)
 		}
-		log.Infof("Response body: %s", string(b))
-		log.Infof("Request: %s-%s", reqInput.Method, reqInput.Endpoint)
+		nc.log.Debugf("Response body: %s", string(b))
+		nc.log.Debugf("Request: %s-%s", reqInput.Method, reqInput.Endpoint)
+
+		_ = r.Body.Close()
+
+		// Another process sharing the cookie store might have logged in already, try its session before logging in
+		r, err = nc.retryWithSharedSession(ctx, reqInput)
+		if err != nil {
+			return nil, err
+		}
+		if r != nil && nc.needsAuthentication(r.StatusCode, reqInput.Endpoint) {
+			_ = r.Body.Close()
+			r = nil
+		}
+	}
 
+	if r == nil {
 		// Request: Unauthorized
-		log.Infof("Re-authenticating...")
-		if err := nc.login(nc.Credentials); err != nil {
+		nc.log.Infof("Re-authenticating...")
+		span.SetAttributes(Attribute{Key: AttrReAuthenticated, Value: true})
+		if err := nc.reAuthenticate(ctx, authGeneration); err != nil {
 			return nil, fmt.Errorf("error re-authenticating: %s", err)
 		}
 
 		// Retry request
-		r, err = nc.Request(ctx, reqInput)
+		r, err = nc.requestWithRetry(ctx, reqInput)
 		if err != nil {
 			if r != nil {
 				_ = r.Body.Close()
@@ -142,13 +371,126 @@ func (nc *NuvlaClient) cimiRequest(ctx context.Context, reqInput *types.RequestO
 		}
 	}
 
+	if r.StatusCode >= http.StatusBadRequest {
+		apiErr := types.NewNuvlaAPIErrorFromResponse(r)
+		nc.log.Debugf("Request returned an error: %s", apiErr)
+		return nil, apiErr
+	}
+
+	return r, nil
+}
+
+// retryWithSharedSession re-executes the request if the persisted cookies were changed by another process, which
+// avoids every process sharing the cookie store logging in at the same time. It returns a nil response if the
+// cookies did not change.
+func (nc *NuvlaClient) retryWithSharedSession(ctx context.Context, reqInput *types.RequestOpts) (*http.Response, error) {
+	if !nc.persistCookie || !nc.cookies.ReloadIfChanged() {
+		return nil, nil
+	}
+
+	nc.log.Debugf("Retrying request with the session shared through the cookie store")
+	r, err := nc.requestWithRetry(ctx, reqInput)
+	if err != nil {
+		if r != nil {
+			_ = r.Body.Close()
+		}
+		return nil, fmt.Errorf("error re-executing request: %s", err)
+	}
 	return r, nil
 }
 
+// reAuthenticate logs in again after a request sent at authGeneration was rejected. Only one re-authentication
+// runs at a time: concurrent callers wait for it and share its result, and callers whose request was sent before
+// another login completed return straight away.
+func (nc *NuvlaClient) reAuthenticate(ctx context.Context, authGeneration uint64) error {
+	nc.authMu.Lock()
+	if nc.authGeneration != authGeneration {
+		nc.authMu.Unlock()
+		nc.log.Debugf("Already re-authenticated since the request was sent")
+		return nil
+	}
+	if call := nc.authCall; call != nil {
+		nc.authMu.Unlock()
+		nc.log.Debugf("Waiting for the re-authentication in progress")
+		select {
+		case <-call.done:
+			return call.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	call := &loginCall{done: make(chan struct{})}
+	nc.authCall = call
+	creds, fromProvider := nc.Credentials, nc.credsFromProvider
+	nc.authMu.Unlock()
+
+	call.err = nc.logInAgain(ctx, creds, fromProvider)
+	if nc.metrics != nil {
+		nc.metrics.ObserveReAuthentication(call.err)
+	}
+
+	nc.authMu.Lock()
+	nc.authCall = nil
+	nc.authMu.Unlock()
+	close(call.done)
+	return call.err
+}
+
+// logInAgain logs in with creds. If they were supplied by the CredentialProvider, or there are none, the
+// credentials are requested from it again so rotated secrets are used. Credentials given explicitly to Login are
+// kept, so the client does not switch to the identity of the provider.
+func (nc *NuvlaClient) logInAgain(ctx context.Context, creds types.LogInParams, fromProvider bool) error {
+	provider := nc.SessionOpts.CredentialProvider
+	if provider != nil && (fromProvider || common.IsNilValueInterface(creds)) {
+		c, err := provider.Credentials(ctx)
+		if err != nil {
+			return fmt.Errorf("error retrieving credentials: %w", err)
+		}
+		return nc.loginWith(ctx, c, true)
+	}
+	if common.IsNilValueInterface(creds) {
+		return ErrNoCredentials
+	}
+
+	return nc.Login(ctx, creds)
+}
+
+// requestWithRetry executes the request, retrying transient failures according to SessionOptions.Retry.
+// The request body is rebuilt from reqInput on every attempt, so JSON and gzip-compressed payloads are replayed safely.
+func (nc *NuvlaClient) requestWithRetry(ctx context.Context, reqInput *types.RequestOpts) (*http.Response, error) {
+	policy := nc.retry
+	if !policy.enabled() {
+		return nc.Request(ctx, reqInput)
+	}
+
+	for attempt := 1; ; attempt++ {
+		r, err := nc.Request(ctx, reqInput)
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(reqInput.Method, r, err) {
+			return r, err
+		}
+
+		delay := policy.delay(attempt, r)
+		if exceedsDeadline(ctx, delay) {
+			nc.log.Debugf("Not retrying [%s] %s: next attempt would exceed the context deadline", reqInput.Method, reqInput.Endpoint)
+			return r, err
+		}
+		if err != nil {
+			nc.log.Debugf("Attempt %d of [%s] %s failed: %s. Retrying in %s", attempt, reqInput.Method, reqInput.Endpoint, err, delay)
+		} else {
+			nc.log.Debugf("Attempt %d of [%s] %s returned %s. Retrying in %s", attempt, reqInput.Method, reqInput.Endpoint, r.Status, delay)
+		}
+		drainAndClose(r)
+
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
 // Get executes the get http method
 // Allow for selective fields to be returned via the selectFields parameter
 
-func (nc *NuvlaClient) Get(ctx context.Context, resourceId string, selectFields []string) (*types.NuvlaResource, error) {
+func (nc *NuvlaClient) Get(ctx context.Context, resourceId string, selectFields []string, opts ...CallOption) (*types.NuvlaResource, error) {
 	// Define request inputs to allow adding select fields
 	r := &types.RequestOpts{
 		Method:   "GET",
@@ -162,9 +504,9 @@ func (nc *NuvlaClient) Get(ctx context.Context, resourceId string, selectFields
 		}
 	}
 
-	resp, err := nc.cimiRequest(ctx, r)
+	resp, err := nc.cimiRequest(ctx, applyCallOptions(r, opts))
 	if err != nil {
-		log.Errorf("Error executing GET request: %s", err)
+		nc.log.Errorf("Error executing GET request: %s", err)
 		return nil, err
 	}
 
@@ -173,23 +515,23 @@ func (nc *NuvlaClient) Get(ctx context.Context, resourceId string, selectFields
 
 // Post executes the post http method
 // Data can be any type, but it will be marshaled into JSON
-func (nc *NuvlaClient) Post(ctx context.Context, endpoint string, data map[string]interface{}) (*http.Response, error) {
+func (nc *NuvlaClient) Post(ctx context.Context, endpoint string, data map[string]interface{}, opts ...CallOption) (*http.Response, error) {
 	r := &types.RequestOpts{
 		Method:   "POST",
 		JsonData: data,
 		Endpoint: nc.buildUriEndPoint(endpoint),
 	}
 
-	resp, err := nc.cimiRequest(ctx, r)
+	resp, err := nc.cimiRequest(ctx, applyCallOptions(r, opts))
 	if err != nil {
-		log.Errorf("Error executing POST request: %s", err)
+		nc.log.Errorf("Error executing POST request: %s", err)
 		return nil, err
 	}
 
 	return resp, nil
 }
 
-func (nc *NuvlaClient) BulkPost(ctx context.Context, endpoint string, data []map[string]interface{}) (*http.Response, error) {
+func (nc *NuvlaClient) BulkPost(ctx context.Context, endpoint string, data []map[string]interface{}, opts ...CallOption) (*http.Response, error) {
 	r := &types.RequestOpts{
 		Method:   "POST",
 		JsonData: data,
@@ -197,16 +539,16 @@ func (nc *NuvlaClient) BulkPost(ctx context.Context, endpoint string, data []map
 		Bulk:     true,
 	}
 
-	resp, err := nc.cimiRequest(ctx, r)
+	resp, err := nc.cimiRequest(ctx, applyCallOptions(r, opts))
 	if err != nil {
-		log.Errorf("Error executing POST request: %s", err)
+		nc.log.Errorf("Error executing POST request: %s", err)
 		return nil, err
 	}
 
 	return resp, nil
 }
 
-func (nc *NuvlaClient) Put(ctx context.Context, uri string, data interface{}, selectFields []string) (*http.Response, error) {
+func (nc *NuvlaClient) Put(ctx context.Context, uri string, data interface{}, selectFields []string, opts ...CallOption) (*http.Response, error) {
 	r := &types.RequestOpts{
 		Method:   "PUT",
 		Endpoint: nc.buildUriEndPoint(uri),
@@ -221,41 +563,60 @@ func (nc *NuvlaClient) Put(ctx context.Context, uri string, data interface{}, se
 		r.Headers["Content-Type"] = "application/json-patch+json"
 	}
 
-	resp, err := nc.cimiRequest(ctx, r)
+	resp, err := nc.cimiRequest(ctx, applyCallOptions(r, opts))
 	if err != nil {
-		log.Errorf("Error executing PUT request: %s", err)
+		nc.log.Errorf("Error executing PUT request: %s", err)
 		return nil, err
 	}
 	return resp, nil
 }
 
-func (nc *NuvlaClient) delete(ctx context.Context, deleteEndpoint string) (*http.Response, error) {
+func (nc *NuvlaClient) delete(ctx context.Context, deleteEndpoint string, opts ...CallOption) (*http.Response, error) {
 	r := &types.RequestOpts{
 		Method:   "DELETE",
 		Endpoint: deleteEndpoint,
 	}
-	resp, err := nc.cimiRequest(ctx, r)
+	resp, err := nc.cimiRequest(ctx, applyCallOptions(r, opts))
 	if err != nil {
-		log.Errorf("Error executing PUT request: %s", err)
+		nc.log.Errorf("Error executing DELETE request: %s", err)
 		return nil, err
 	}
 	return resp, nil
 }
 
-func (nc *NuvlaClient) Operation(ctx context.Context, resourceId, operation string, data map[string]interface{}) (*http.Response, error) {
-	return nc.Post(ctx, nc.buildOperationUriEndPoint(resourceId, operation), data)
+// Operation executes the given operation on the resource and returns the decoded Nuvla response
+func (nc *NuvlaClient) Operation(ctx context.Context, resourceId, operation string, data map[string]interface{}, opts ...CallOption) (*types.NuvlaResponse, error) {
+	resp, err := nc.Post(ctx, nc.buildOperationUriEndPoint(resourceId, operation), data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewNuvlaResponseFromResponse(resp)
 }
 
-func (nc *NuvlaClient) BulkOperation(ctx context.Context, resourceId string, operation string, data []map[string]interface{}) (*http.Response, error) {
-	return nc.BulkPost(ctx, nc.buildOperationUriEndPoint(resourceId, operation), data)
+// BulkOperation executes the given operation on every resource of the collection matching the filter in data
+func (nc *NuvlaClient) BulkOperation(ctx context.Context, resourceId string, operation string, data []map[string]interface{}, opts ...CallOption) (*types.NuvlaResponse, error) {
+	resp, err := nc.BulkPost(ctx, nc.buildOperationUriEndPoint(resourceId, operation), data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewNuvlaResponseFromResponse(resp)
 }
 
-func (nc *NuvlaClient) Edit(ctx context.Context, resourceId string, data map[string]interface{}, toSelect []string) (*http.Response, error) {
-	return nc.Put(ctx, resourceId, data, toSelect)
+// Edit updates the resource with data. The updated resource is available in the response Data field.
+func (nc *NuvlaClient) Edit(ctx context.Context, resourceId string, data map[string]interface{}, toSelect []string, opts ...CallOption) (*types.NuvlaResponse, error) {
+	resp, err := nc.Put(ctx, resourceId, data, toSelect, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewNuvlaResponseFromResponse(resp)
 }
 
-func (nc *NuvlaClient) Delete(ctx context.Context, resourceId string) (*http.Response, error) {
-	return nc.delete(ctx, nc.buildOperationUriEndPoint(resourceId, "delete"))
+func (nc *NuvlaClient) Delete(ctx context.Context, resourceId string, opts ...CallOption) (*types.NuvlaResponse, error) {
+	resp, err := nc.delete(ctx, nc.buildUriEndPoint(resourceId), opts...)
+	if err != nil {
+		return nil, err
+	}
+	return types.NewNuvlaResponseFromResponse(resp)
 }
 
 type SearchOptions struct {
@@ -279,7 +640,7 @@ func NewDefaultSearchOptions() *SearchOptions {
 	}
 }
 
-func (nc *NuvlaClient) Search(ctx context.Context, resourceType string, opts *SearchOptions) (*resources.NuvlaResourceCollection, error) {
+func (nc *NuvlaClient) Search(ctx context.Context, resourceType string, opts *SearchOptions, callOpts ...CallOption) (*resources.NuvlaResourceCollection, error) {
 
 	r := &types.RequestOpts{
 		Method:   "PUT",
@@ -288,44 +649,39 @@ func (nc *NuvlaClient) Search(ctx context.Context, resourceType string, opts *Se
 		JsonData: nil,
 		Data:     common.GetCleanMapFromStruct(opts),
 	}
-	resp, err := nc.cimiRequest(ctx, r)
+	resp, err := nc.cimiRequest(ctx, applyCallOptions(r, callOpts))
 
 	if err != nil {
-		log.Errorf("Error executing GET request: %s", err)
+		nc.log.Errorf("Error executing GET request: %s", err)
 		return nil, err
 	}
 	collection, err := resources.NewCollectionFromResponse(resp)
 	if err != nil {
-		log.Errorf("Error creating resource collection: %s", err)
+		nc.log.Errorf("Error creating resource collection: %s", err)
 		return nil, err
 	}
 	return collection, err
 }
 
-// Add creates a new resource of the given type and returns its ID
-func (nc *NuvlaClient) Add(ctx context.Context, resourceType resources.NuvlaResourceType, data map[string]interface{}) (*types.NuvlaID, error) {
-	res, err := nc.Post(ctx, string(resourceType), data)
+// Add creates a new resource of the given type. The ID of the new resource is available through
+// NuvlaResponse.GetResourceId.
+func (nc *NuvlaClient) Add(ctx context.Context, resourceType resources.NuvlaResourceType, data map[string]interface{}, opts ...CallOption) (*types.NuvlaResponse, error) {
+	resp, err := nc.Post(ctx, string(resourceType), data, opts...)
 	if err != nil {
-		log.Errorf("Error adding %s: %s", resourceType, err)
+		nc.log.Errorf("Error adding %s: %s", resourceType, err)
 		return nil, err
 	}
 
-	var resData map[string]interface{}
-
-	bodyBytes, err := io.ReadAll(res.Body)
-	defer res.Body.Close()
+	res, err := types.NewNuvlaResponseFromResponse(resp)
 	if err != nil {
-		log.Errorf("Error reading response body, cannot extract ID: %s", err)
+		nc.log.Errorf("Error decoding response, cannot extract ID: %s", err)
 		return nil, err
 	}
 
-	err = json.Unmarshal(bodyBytes, &resData)
-	if err != nil {
-		log.Errorf("Error unmarshaling response body, cannot extract ID: %s", err)
-		return nil, err
+	if res.ResourceId == "" {
+		return nil, fmt.Errorf("resource-id not found in response to add %s", resourceType)
 	}
+	nc.log.Debugf("ID of new %s: %s", resourceType, res.ResourceId)
 
-	log.Debugf("ID of new %s: %s", resourceType, resData)
-
-	return types.NewNuvlaIDFromId(resData["resource-id"].(string)), nil
+	return res, nil
 }
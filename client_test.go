@@ -0,0 +1,529 @@
+package api_client_go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+const testSessionCookie = "com.sixsq.nuvla.cookie"
+
+// testNuvlaServer is a minimal Nuvla API accepting the requests carrying the session cookie of the last login
+type testNuvlaServer struct {
+	*httptest.Server
+	logins atomic.Int32
+	token  atomic.Value
+	// loginDelay keeps logins in progress long enough for concurrent requests to pile up
+	loginDelay atomic.Int64
+	// sessionSearches counts the searches of the current session
+	sessionSearches atomic.Int32
+	// anonymous answers the session searches with no session, as for an anonymous user
+	anonymous atomic.Bool
+	// deleteStatus, if set, is the error status code of the deletions
+	deleteStatus atomic.Int32
+	// activeClaim is the identity the session acts on behalf of, changed by the switch-group operation
+	activeClaim atomic.Value
+
+	mu          sync.Mutex
+	deletes     []string
+	loginBodies []map[string]interface{}
+}
+
+// loginTemplates returns the templates sent by the logins
+func (s *testNuvlaServer) loginTemplates() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	templates := make([]interface{}, 0, len(s.loginBodies))
+	for _, body := range s.loginBodies {
+		templates = append(templates, body["template"])
+	}
+	return templates
+}
+
+// deleted returns the ids of the resources deleted
+func (s *testNuvlaServer) deleted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.deletes...)
+}
+
+func newTestNuvlaServer(t *testing.T) *testNuvlaServer {
+	s := &testNuvlaServer{}
+	s.token.Store("")
+	s.activeClaim.Store("user/1")
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// expireSession invalidates the current session, so the next requests are rejected with 401
+func (s *testNuvlaServer) expireSession() {
+	s.token.Store("expired")
+}
+
+func (s *testNuvlaServer) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodPost && r.URL.Path == types.SessionEndpoint {
+		time.Sleep(time.Duration(s.loginDelay.Load()))
+		var body map[string]interface{}
+		_ = decodeRequestBody(r, &body)
+		s.mu.Lock()
+		s.loginBodies = append(s.loginBodies, body)
+		s.mu.Unlock()
+		token := fmt.Sprintf("token-%d", s.logins.Add(1))
+		s.token.Store(token)
+		http.SetCookie(w, &http.Cookie{Name: testSessionCookie, Value: token, Path: "/", Expires: time.Now().Add(time.Hour)})
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 201, "resource-id": "session/1"})
+		return
+	}
+
+	// The login methods are listed without session
+	if r.Method == http.MethodPut && r.URL.Path == "/api/session-template" {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": 2, "resources": []interface{}{
+			map[string]interface{}{"id": "session-template/password", "method": "password", "instance": "password"},
+			map[string]interface{}{"id": "session-template/github-nuvla", "method": "github", "instance": "nuvla",
+				"group": "Nuvla", "redirect-url": "https://nuvla.io/ui/sign-in"},
+		}})
+		return
+	}
+
+	if r.Method == http.MethodPut && r.URL.Path == "/api/session" {
+		s.sessionSearches.Add(1)
+	}
+	c, err := r.Cookie(testSessionCookie)
+	if err != nil || c.Value != s.token.Load().(string) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 401, "message": "invalid session"})
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/")
+	switch {
+	case r.Method == http.MethodPut && id == "session":
+		sessions := []interface{}{}
+		if !s.anonymous.Load() {
+			sessions = append(sessions, map[string]interface{}{
+				"id": "session/1", "user": "user/1", "active-claim": s.activeClaim.Load(), "roles": "group/nuvla-user user/1",
+				"groups": "group/team-a group/team-b",
+				"expiry": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": len(sessions), "resources": sessions})
+	case r.Method == http.MethodDelete:
+		s.mu.Lock()
+		s.deletes = append(s.deletes, id)
+		s.mu.Unlock()
+		if status := int(s.deleteStatus.Load()); status != 0 {
+			w.WriteHeader(status)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "message": "cannot delete " + id})
+			return
+		}
+		if id == "session/1" {
+			s.token.Store("deleted")
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "message": id + " deleted", "resource-id": id})
+	case r.Method == http.MethodPost && id == "session/1/switch-group":
+		var body map[string]interface{}
+		_ = decodeRequestBody(r, &body)
+		claim, _ := body["claim"].(string)
+		if claim != "user/1" && claim != "group/team-a" && claim != "group/team-b" {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 403, "message": "cannot switch to " + claim})
+			return
+		}
+		// Nuvla issues a new session cookie carrying the active claim
+		token := fmt.Sprintf("token-%s", claim)
+		s.token.Store(token)
+		s.activeClaim.Store(claim)
+		http.SetCookie(w, &http.Cookie{Name: testSessionCookie, Value: token, Path: "/", Expires: time.Now().Add(time.Hour)})
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "message": "switched to " + claim})
+	case r.Method == http.MethodPost && strings.Contains(id, "/"):
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "message": "operation executed", "resource-id": id})
+	default:
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "name": "test"})
+	}
+}
+
+func newTestClient(t *testing.T, s *testNuvlaServer) *NuvlaClient {
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithoutPersistCookie, ReAuthenticateSession)
+	if err := c.LoginApiKeys(context.Background(), "credential/key", "secret"); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	return c
+}
+
+func TestNuvlaClient_ConcurrentRequests(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	c := newTestClient(t, s)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_, err := c.Get(ctx, "nuvlabox/1", nil)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			r, err := c.Put(ctx, "nuvlabox/1", map[string]interface{}{"name": "test"}, nil)
+			if err == nil {
+				_ = r.Body.Close()
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := c.Operation(ctx, "nuvlabox/1", "heartbeat", nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent request failed: %s", err)
+		}
+	}
+	if n := s.logins.Load(); n != 1 {
+		t.Errorf("expected 1 login, got %d", n)
+	}
+}
+
+func TestNuvlaClient_SingleFlightReAuthentication(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	c := newTestClient(t, s)
+	ctx := context.Background()
+
+	s.expireSession()
+	s.loginDelay.Store(int64(50 * time.Millisecond))
+
+	const requests = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Get(ctx, "nuvlabox/1", nil)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("request failed after re-authentication: %s", err)
+		}
+	}
+	// One login from newTestClient and one re-authentication for all the rejected requests
+	if n := s.logins.Load(); n != 2 {
+		t.Errorf("expected 2 logins, got %d", n)
+	}
+}
+
+func TestNuvlaClient_ConcurrentCredentialsAccess(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	c := newTestClient(t, s)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = c.LoginApiKeys(context.Background(), "credential/key", "secret")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = c.CurrentCredentials()
+			_ = c.NeedToLogin()
+		}()
+	}
+	wg.Wait()
+
+	if c.CurrentCredentials() == nil {
+		t.Error("expected credentials after login")
+	}
+}
+
+func TestNuvlaClient_EditResourceWithConflictingEnvelopeFields(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// Edit responses are the resource itself: a nuvlabox location is a list of coordinates and a
+		// nuvlabox-status status is a string
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":       strings.TrimPrefix(r.URL.Path, "/api/"),
+			"location": []float32{46.2, 6.1, 400},
+			"status":   "OPERATIONAL",
+		})
+	}))
+	defer srv.Close()
+
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie)
+	res, err := c.Edit(context.Background(), "nuvlabox-status/1", map[string]interface{}{"status": "OPERATIONAL"}, nil)
+	if err != nil {
+		t.Fatalf("edit failed: %s", err)
+	}
+	if res.ResourceId != "nuvlabox-status/1" {
+		t.Errorf("expected resource id nuvlabox-status/1, got %q", res.ResourceId)
+	}
+	if res.Data["status"] != "OPERATIONAL" {
+		t.Errorf("expected status OPERATIONAL in data, got %v", res.Data["status"])
+	}
+	if _, ok := res.Data["location"].([]interface{}); !ok {
+		t.Errorf("expected location list in data, got %v", res.Data["location"])
+	}
+	if res.Status != 0 || res.Location != "" {
+		t.Errorf("expected no envelope status and location, got %d and %q", res.Status, res.Location)
+	}
+}
+
+func TestNuvlaClient_IsAuthenticatedContext(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare changes the client or the server after the login
+		prepare      func(s *testNuvlaServer, c *NuvlaClient)
+		want         bool
+		wantSearches int32
+	}{
+		{
+			name:         "valid session",
+			prepare:      func(*testNuvlaServer, *NuvlaClient) {},
+			want:         true,
+			wantSearches: 1,
+		},
+		{
+			name: "expired cookie",
+			prepare: func(_ *testNuvlaServer, c *NuvlaClient) {
+				c.cookies.mu.Lock()
+				defer c.cookies.mu.Unlock()
+				for _, cookie := range c.cookies.sessionCookies {
+					cookie.Expires = time.Now().Add(-time.Minute)
+				}
+			},
+			// The server is not asked
+			wantSearches: 0,
+		},
+		{
+			name:         "valid cookie rejected by the server",
+			prepare:      func(s *testNuvlaServer, _ *NuvlaClient) { s.expireSession() },
+			wantSearches: 1,
+		},
+		{
+			name:         "anonymous search without session",
+			prepare:      func(s *testNuvlaServer, _ *NuvlaClient) { s.anonymous.Store(true) },
+			wantSearches: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestNuvlaServer(t)
+			c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithoutPersistCookie)
+			if err := c.LoginApiKeys(context.Background(), "credential/key", "secret"); err != nil {
+				t.Fatalf("login failed: %s", err)
+			}
+			tt.prepare(s, c)
+
+			if got := c.IsAuthenticatedContext(context.Background()); got != tt.want {
+				t.Errorf("IsAuthenticatedContext() = %t, want %t", got, tt.want)
+			}
+			if n := s.sessionSearches.Load(); n != tt.wantSearches {
+				t.Errorf("expected %d session searches, got %d", tt.wantSearches, n)
+			}
+			if n := s.logins.Load(); n != 1 {
+				t.Errorf("expected no login besides the first one, got %d logins", n)
+			}
+		})
+	}
+}
+
+func TestNuvlaClient_CurrentSession(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	c := newTestClient(t, s)
+	ctx := context.Background()
+
+	session, err := c.CurrentSession(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if session.Id != "session/1" || session.User != "user/1" || session.ActiveClaim != "user/1" || session.IsExpired() {
+		t.Errorf("unexpected session %+v", session)
+	}
+	if roles := session.GetRoles(); len(roles) != 2 || roles[0] != "group/nuvla-user" {
+		t.Errorf("unexpected roles %v", roles)
+	}
+
+	s.anonymous.Store(true)
+	if _, err := c.CurrentSession(ctx); !errors.Is(err, types.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized without session, got %v", err)
+	}
+}
+
+// failingClearStore is a cookie store which cannot remove the cookies
+type failingClearStore struct {
+	*MemoryCookieStore
+}
+
+var errClearCookies = errors.New("cannot remove cookies")
+
+func (s failingClearStore) Clear(*url.URL) error {
+	return errClearCookies
+}
+
+func TestNuvlaClient_Logout(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	store := NewMemoryCookieStore()
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithCookieStore(store), ReAuthenticateSession)
+	ctx := context.Background()
+	if err := c.LoginApiKeys(ctx, "credential/key", "secret"); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	endpoint, _ := url.Parse(s.URL)
+	if cookies, _ := store.Load(endpoint); len(cookies) == 0 {
+		t.Fatal("expected the session cookie in the store after login")
+	}
+
+	if err := c.Logout(ctx); err != nil {
+		t.Fatalf("logout failed: %s", err)
+	}
+	if deleted := s.deleted(); len(deleted) != 1 || deleted[0] != "session/1" {
+		t.Errorf("expected the session to be deleted in the server, got %v", deleted)
+	}
+	if cookies, _ := store.Load(endpoint); len(cookies) != 0 {
+		t.Errorf("expected no cookies in the store after logout, got %v", cookies)
+	}
+	if c.CurrentCredentials() != nil || !c.NeedToLogin() {
+		t.Error("expected no credentials and no session after logout")
+	}
+	if n := s.logins.Load(); n != 1 {
+		t.Errorf("expected no login during logout, got %d logins", n)
+	}
+}
+
+func TestNuvlaClient_LogoutErrors(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	s.deleteStatus.Store(http.StatusInternalServerError)
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithCookieStore(failingClearStore{NewMemoryCookieStore()}))
+	if err := c.LoginApiKeys(context.Background(), "credential/key", "secret"); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+
+	err := c.Logout(context.Background())
+	var apiErr *types.NuvlaAPIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected the error deleting the session, got %v", err)
+	}
+	if !errors.Is(err, errClearCookies) {
+		t.Errorf("expected the error removing the cookies, got %v", err)
+	}
+	if c.CurrentCredentials() != nil || !c.NeedToLogin() {
+		t.Error("expected no credentials and no session after a failed logout")
+	}
+}
+
+func TestNuvlaClient_LogoutExpiredSession(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	c := newTestClient(t, s)
+	c.cookies.mu.Lock()
+	for _, cookie := range c.cookies.sessionCookies {
+		cookie.Expires = time.Now().Add(-time.Minute)
+	}
+	c.cookies.mu.Unlock()
+
+	if err := c.Logout(context.Background()); err != nil {
+		t.Fatalf("logout failed: %s", err)
+	}
+	// With ReAuthenticate, looking the session up would log in again just to delete the new session
+	if n := s.logins.Load(); n != 1 {
+		t.Errorf("expected no login during logout, got %d logins", n)
+	}
+	if n, deleted := s.sessionSearches.Load(), s.deleted(); n != 0 || len(deleted) != 0 {
+		t.Errorf("expected no request to the server, got %d session searches and deletions %v", n, deleted)
+	}
+	if c.CurrentCredentials() != nil {
+		t.Error("expected no credentials after logout")
+	}
+}
+
+func TestNuvlaClient_ListSessionTemplates(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	// No login: the templates are listed to choose how to log in
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithoutPersistCookie)
+
+	templates, err := c.ListSessionTemplates(context.Background())
+	if err != nil {
+		t.Fatalf("error listing session templates: %s", err)
+	}
+	if len(templates) != 2 {
+		t.Fatalf("expected 2 templates, got %d", len(templates))
+	}
+	if templates[0].Id != types.HrefSessionTemplatePassword || templates[0].Method != "password" {
+		t.Errorf("unexpected password template %+v", templates[0])
+	}
+	github := templates[1]
+	if github.Method != "github" || github.Instance != "nuvla" || github.Group != "Nuvla" ||
+		github.RedirectUrl != "https://nuvla.io/ui/sign-in" {
+		t.Errorf("unexpected github template %+v", github)
+	}
+}
+
+func TestNuvlaClient_LoginWithTemplateParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params types.LogInParams
+		want   map[string]interface{}
+	}{
+		{
+			name:   "template",
+			params: types.NewTemplateLogInParams("session-template/password", map[string]string{"username": "jane", "password": "secret"}),
+			want:   map[string]interface{}{"href": "session-template/password", "username": "jane", "password": "secret"},
+		},
+		{
+			name:   "token",
+			params: types.NewTokenLogInParams("access-token"),
+			want:   map[string]interface{}{"href": types.HrefSessionTemplateMitreIdToken, "token": "access-token"},
+		},
+		{
+			name:   "token with custom template",
+			params: &types.TokenLogInParams{Token: "access-token", Href: "session-template/oidc-nuvla"},
+			want:   map[string]interface{}{"href": "session-template/oidc-nuvla", "token": "access-token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestNuvlaServer(t)
+			c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithoutPersistCookie)
+
+			if err := c.Login(context.Background(), tt.params); err != nil {
+				t.Fatalf("login failed: %s", err)
+			}
+			templates := s.loginTemplates()
+			if len(templates) != 1 || fmt.Sprint(templates[0]) != fmt.Sprint(tt.want) {
+				t.Errorf("expected the login template %v, got %v", tt.want, templates)
+			}
+			if c.CurrentCredentials() != tt.params {
+				t.Errorf("expected the parameters to be kept to re-authenticate")
+			}
+			if _, err := c.Get(context.Background(), "nuvlabox/1", nil); err != nil {
+				t.Errorf("request after login failed: %s", err)
+			}
+		})
+	}
+}
@@ -6,47 +6,67 @@ import (
 	"errors"
 	"fmt"
 	nuvla "github.com/nuvla/api-client-go"
+	"github.com/nuvla/api-client-go/cimi"
 	"github.com/nuvla/api-client-go/clients/resources"
 	"github.com/nuvla/api-client-go/types"
-	log "github.com/sirupsen/logrus"
+	"sync"
 )
 
+// NuvlaDeploymentClient is safe for concurrent use, except for UpdateSessionFromDeploymentCredentials, which
+// replaces the underlying NuvlaClient and must be called before sharing the client between goroutines.
 type NuvlaDeploymentClient struct {
 	*nuvla.NuvlaClient
 
 	deploymentId *types.NuvlaID
 
+	// mu guards deploymentResource
+	mu                 sync.RWMutex
 	deploymentResource *resources.DeploymentResource
+
+	resourceClient  *ResourceClient[*resources.DeploymentResource]
+	parameterClient *ResourceClient[*resources.DeploymentParameterResource]
 }
 
 func NewNuvlaDeploymentClient(deploymentId string, client *nuvla.NuvlaClient) *NuvlaDeploymentClient {
-	return &NuvlaDeploymentClient{
-		NuvlaClient:  client,
+	dc := &NuvlaDeploymentClient{
 		deploymentId: types.NewNuvlaIDFromId(deploymentId),
 	}
+	dc.setNuvlaClient(client)
+	return dc
+}
+
+// setNuvlaClient replaces the underlying NuvlaClient, rebinding the typed resource clients to it
+func (dc *NuvlaDeploymentClient) setNuvlaClient(client *nuvla.NuvlaClient) {
+	dc.NuvlaClient = client
+	dc.resourceClient = NewResourceClient[*resources.DeploymentResource](client, resources.DeploymentType)
+	dc.parameterClient = NewResourceClient[*resources.DeploymentParameterResource](client, resources.DeploymentParameterType)
 }
 
 // UpdateSessionFromDeploymentCredentials after retrieving
 func (dc *NuvlaDeploymentClient) UpdateSessionFromDeploymentCredentials(ctx context.Context) error {
-	if dc.deploymentResource == nil {
+	if dc.GetResource() == nil {
 		if err := dc.UpdateResource(ctx); err != nil {
-			log.Errorf("Error updating Deployment resource %s", dc.deploymentId)
+			dc.Logger().Errorf("Error updating Deployment resource %s", dc.deploymentId)
 			return err
 		}
 	}
 
-	if dc.deploymentResource.ApiCredentials.ApiKey == "" || dc.deploymentResource.ApiCredentials.ApiSecret == "" {
-		log.Errorf("Deployment %s does not have API credentials", dc.deploymentId)
+	creds := dc.GetResource().ApiCredentials
+	if creds.ApiKey == "" || creds.ApiSecret == "" {
+		dc.Logger().Errorf("Deployment %s does not have API credentials", dc.deploymentId)
 		return fmt.Errorf("deployment %s does not have API credentials", dc.deploymentId)
 	}
 	customOpts := dc.NuvlaClient.SessionOpts
 	customOpts.CookieFile = ""
 	customOpts.PersistCookie = false
+	customOpts.CookieStore = nil
+	// The provider supplies the identity of the parent client, never the one of the deployment
+	customOpts.CredentialProvider = nil
 
-	dc.NuvlaClient = nuvla.NewNuvlaClient(nil, &customOpts)
-	err := dc.LoginApiKeys(dc.deploymentResource.ApiCredentials.ApiKey, dc.deploymentResource.ApiCredentials.ApiSecret)
+	dc.setNuvlaClient(nuvla.NewNuvlaClient(nil, &customOpts))
+	err := dc.LoginApiKeys(ctx, creds.ApiKey, creds.ApiSecret)
 	if err != nil {
-		log.Errorf("Error logging in with deployment credentials: %s", err)
+		dc.Logger().Errorf("Error logging in with deployment credentials: %s", err)
 		return err
 	}
 	return nil
@@ -57,28 +77,16 @@ func (dc *NuvlaDeploymentClient) SetDeploymentState() error {
 }
 
 func (dc *NuvlaDeploymentClient) UpdateResource(ctx context.Context) error {
-	res, err := dc.Get(ctx, dc.deploymentId.Id, nil)
-	if err != nil {
-		log.Infof("Error updating Deployment resource %s", dc.deploymentId)
-		return nil
-	}
-
-	if dc.deploymentResource == nil {
-		dc.deploymentResource = &resources.DeploymentResource{}
-	}
-
-	b, err := json.Marshal(res.Data)
+	res, err := dc.resourceClient.Get(ctx, dc.deploymentId.Id, nil)
 	if err != nil {
-		log.Errorf("Error marshaling deployment resource response data to bytes")
+		dc.Logger().Infof("Error updating Deployment resource %s: %s", dc.deploymentId, err)
 		return err
 	}
 
-	err = json.Unmarshal(b, dc.deploymentResource)
-	if err != nil {
-		log.Error("Error unmarshalling response into DeploymentResource structure")
-		return err
-	}
-	log.Infof("Successfully updated deployment resource")
+	dc.mu.Lock()
+	dc.deploymentResource = res
+	dc.mu.Unlock()
+	dc.Logger().Debugf("Successfully updated deployment resource")
 	return nil
 }
 
@@ -91,39 +99,46 @@ func (dc *NuvlaDeploymentClient) GetType() resources.NuvlaResourceType {
 }
 
 func (dc *NuvlaDeploymentClient) GetResourceMap() (map[string]interface{}, error) {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
 
 	var mapRes map[string]interface{}
 	if err := MarshalResourceIntoMap(dc.deploymentResource, mapRes); err != nil {
-		log.Errorf("Error marshaling DeploymentResource to map")
+		dc.Logger().Errorf("Error marshaling DeploymentResource to map")
 		return nil, err
 	}
 
 	return mapRes, nil
 }
 
+// GetResource returns the last retrieved deployment resource, which must not be modified
 func (dc *NuvlaDeploymentClient) GetResource() *resources.DeploymentResource {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
 	return dc.deploymentResource
 }
 
 func (dc *NuvlaDeploymentClient) PrintResource() {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
 	p, err := json.MarshalIndent(dc.deploymentResource, "", "  ")
 	if err != nil {
-		log.Debugf("Error Marshaling %s resource, cannot print", dc.GetType())
+		dc.Logger().Debugf("Error Marshaling %s resource, cannot print", dc.GetType())
 		return
 	}
 
-	log.Infof("%s resource: \n %s", dc.GetType(), string(p))
+	dc.Logger().Infof("%s resource: \n %s", dc.GetType(), string(p))
 }
 
 func (dc *NuvlaDeploymentClient) SetState(ctx context.Context, state resources.DeploymentState) error {
-	log.Infof("Setting deployment state %s...", state)
+	dc.Logger().Infof("Setting deployment state %s...", state)
 	res, err := dc.Edit(ctx, dc.GetId(), map[string]interface{}{"state": state}, nil)
 	if err != nil {
-		log.Errorf("Error setting deployment state %s: %s", state, err)
+		dc.Logger().Errorf("Error setting deployment state %s: %s", state, err)
 		return err
 	}
-	PrintResponse(res)
-	log.Infof("Setting deployment state %s... Success.", state)
+	logResponse(dc.Logger(), res)
+	dc.Logger().Infof("Setting deployment state %s... Success.", state)
 	return nil
 }
 
@@ -132,54 +147,42 @@ func (dc *NuvlaDeploymentClient) SetStateStarted(ctx context.Context) error {
 }
 
 func (dc *NuvlaDeploymentClient) searchParameter(ctx context.Context, parentId, paramName, nodeId string) (*resources.DeploymentParameterResource, error) {
-	filters := fmt.Sprintf("parent='%s' and name='%s'", parentId, paramName)
+	filter := cimi.And(cimi.Eq("parent", parentId), cimi.Eq("name", paramName))
 	if nodeId != "" {
-		// Concatenate node-id='nodeId'
-		filters = filters + fmt.Sprintf(" and node-id='%s'", nodeId)
+		filter = filter.And(cimi.Eq("node-id", nodeId))
 	}
 
 	// Search opts
 	opts := &nuvla.SearchOptions{
-		Filter: filters,
+		Filter: filter.String(),
 	}
-	parameters, err := dc.Search(ctx, string(resources.DeploymentParameterType), opts)
-	// TODO: See if err != nil should be consider as resource not found error
+	parameters, err := dc.parameterClient.Search(ctx, opts)
 	if err != nil {
-		log.Debugf("Error searching parameter %s: %s", paramName, err)
+		dc.Logger().Debugf("Error searching parameter %s: %s", paramName, err)
+		return nil, err
 	}
 
-	if parameters == nil || parameters.Count <= 0 {
-		log.Warnf("Parameter %s not found", paramName)
+	if parameters.Count <= 0 || len(parameters.Resources) == 0 {
+		dc.Logger().Warnf("Parameter %s not found", paramName)
 		return nil, types.NewResourceNotFoundError(resources.DeploymentParameterType, "")
 	}
 
-	param := &resources.DeploymentParameterResource{}
-	err = resources.NewResourceFromMap(parameters.Resources[0], param)
-	if err != nil {
-		return nil, err
-	}
-	return param, nil
+	return parameters.Resources[0], nil
 }
 
 func (dc *NuvlaDeploymentClient) SearchParameter(ctx context.Context, parentId, paramName, nodeId string) *resources.DeploymentParameterResource {
 	param, err := dc.searchParameter(ctx, parentId, paramName, nodeId)
 	if err != nil {
-		log.Errorf("Error getting parameter %s: %s", paramName, err)
+		dc.Logger().Errorf("Error getting parameter %s: %s", paramName, err)
 		return nil
 	}
 	return param
 }
 
 func (dc *NuvlaDeploymentClient) GetParameter(ctx context.Context, paramId string, paramSelect []string) (*resources.DeploymentParameterResource, error) {
-	res, err := dc.Get(ctx, paramId, paramSelect)
-	if err != nil {
-		log.Errorf("Error getting parameter %s: %s", paramId, err)
-		return nil, err
-	}
-
-	param := &resources.DeploymentParameterResource{}
-	err = resources.NewResourceFromMap(res.Data, param)
+	param, err := dc.parameterClient.Get(ctx, paramId, paramSelect)
 	if err != nil {
+		dc.Logger().Errorf("Error getting parameter %s: %s", paramId, err)
 		return nil, err
 	}
 	return param, nil
@@ -192,7 +195,7 @@ func (dc *NuvlaDeploymentClient) CreateParameter(ctx context.Context, userId str
 	}
 
 	if paramOpts.Parent == "" || paramOpts.Name == "" || userId == "" {
-		log.Errorf("Parent, Name and UserId are required to create a parameter")
+		dc.Logger().Errorf("Parent, Name and UserId are required to create a parameter")
 		jsOpts, _ := json.Marshal(paramOpts)
 		var m map[string]interface{}
 		_ = json.Unmarshal(jsOpts, &m)
@@ -206,11 +209,8 @@ func (dc *NuvlaDeploymentClient) CreateParameter(ctx context.Context, userId str
 	paramOpts.Acl = aclMap
 
 	// Create parameter
-	var m map[string]interface{}
-	jsOpts, _ := json.Marshal(paramOpts)
-	_ = json.Unmarshal(jsOpts, &m)
 	//ATM we don't use the ID of the parameter
-	_, err := dc.Add(ctx, resources.DeploymentParameterType, m)
+	_, err := dc.parameterClient.Add(ctx, paramOpts)
 	return err
 }
 
@@ -225,38 +225,32 @@ func (dc *NuvlaDeploymentClient) UpdateParameter(ctx context.Context, userId str
 	paramData, err := dc.searchParameter(ctx, paramOpts.Parent, paramOpts.Name, paramOpts.NodeId)
 
 	if err != nil {
-		var resourceNotFoundError *types.ResourceNotFoundError
-		if errors.As(err, &resourceNotFoundError) {
-			log.Infof("Parameter %s not found, creating it...", paramOpts.Name)
+		if errors.Is(err, types.ErrNotFound) {
+			dc.Logger().Infof("Parameter %s not found, creating it...", paramOpts.Name)
 			return dc.CreateParameter(ctx, userId, opts...)
-		} else {
-			log.Errorf("Error getting parameter %s: %s", paramOpts.Name, err)
 		}
+		dc.Logger().Errorf("Error getting parameter %s: %s", paramOpts.Name, err)
+		return err
 	}
 
 	var data map[string]interface{}
 	jsOpts, err := json.Marshal(paramOpts)
 	if err != nil {
-		log.Errorf("Error marshaling parameter %s: %w", paramOpts.Name, err)
+		dc.Logger().Errorf("Error marshaling parameter %s: %s", paramOpts.Name, err)
 	}
 
 	err = json.Unmarshal(jsOpts, &data)
 	if err != nil {
-		log.Errorf("Error unmarshaling parameter %s: %w", paramOpts.Name, err)
+		dc.Logger().Errorf("Error unmarshaling parameter %s: %s", paramOpts.Name, err)
 	}
 
-	log.Debugf("Updating parameter %s...", paramOpts.Name)
-	resp, err := dc.Edit(ctx, paramData.Id, data, nil)
-	if resp != nil {
-		if err := resp.Body.Close(); err != nil {
-			log.Errorf("Error closing response body: %s", err)
-		}
-	}
+	dc.Logger().Debugf("Updating parameter %s...", paramOpts.Name)
+	_, err = dc.Edit(ctx, paramData.Id, data, nil)
 	if err != nil {
-		log.Errorf("Error updating parameter %s: %s", paramOpts.Name, err)
+		dc.Logger().Errorf("Error updating parameter %s: %s", paramOpts.Name, err)
 		return err
 	}
 
-	log.Debugf("Parameter %s updated", paramOpts.Name)
+	dc.Logger().Debugf("Parameter %s updated", paramOpts.Name)
 	return nil
 }
@@ -1 +1,150 @@
 package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	nuvla "github.com/nuvla/api-client-go"
+	"github.com/nuvla/api-client-go/clients/resources"
+	"github.com/nuvla/api-client-go/types"
+)
+
+// testLoginServer accepts any API key and records the keys used to log in
+type testLoginServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	keys    []string
+	expired bool
+}
+
+func newTestLoginServer(t *testing.T) *testLoginServer {
+	s := &testLoginServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testLoginServer) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method == http.MethodPost && r.URL.Path == types.SessionEndpoint {
+		var body struct {
+			Template struct {
+				Key string `json:"key"`
+			} `json:"template"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		s.keys = append(s.keys, body.Template.Key)
+		s.expired = false
+		http.SetCookie(w, &http.Cookie{Name: "com.sixsq.nuvla.cookie", Value: fmt.Sprint(len(s.keys)), Path: "/"})
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 201, "resource-id": "session/1"})
+		return
+	}
+	if _, err := r.Cookie("com.sixsq.nuvla.cookie"); err != nil || s.expired {
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 401, "message": "invalid session"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":              "deployment/1",
+		"api-credentials": map[string]string{"api-key": "credential/deployment", "api-secret": "deployment-secret"},
+	})
+}
+
+func (s *testLoginServer) expireSession() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expired = true
+}
+
+func (s *testLoginServer) loginKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.keys...)
+}
+
+func TestNuvlaDeploymentClient_UpdateSessionFromDeploymentCredentials(t *testing.T) {
+	s := newTestLoginServer(t)
+	provider := &nuvla.StaticCredentialProvider{Params: types.NewApiKeyLogInParams("credential/parent", "parent-secret")}
+	ctx := context.Background()
+
+	parent, err := nuvla.NewAuthenticatedNuvlaClient(ctx, nil, nuvla.WithEndpoint(s.URL), nuvla.WithoutPersistCookie,
+		nuvla.WithOutCompressSession, nuvla.ReAuthenticateSession, nuvla.WithCredentialProvider(provider))
+	if err != nil {
+		t.Fatalf("parent login failed: %s", err)
+	}
+
+	dc := NewNuvlaDeploymentClient("deployment/1", parent)
+	if err := dc.UpdateSessionFromDeploymentCredentials(ctx); err != nil {
+		t.Fatalf("deployment login failed: %s", err)
+	}
+
+	s.expireSession()
+	if err := dc.UpdateResource(ctx); err != nil {
+		t.Fatalf("request after re-authentication failed: %s", err)
+	}
+
+	want := []string{"credential/parent", "credential/deployment", "credential/deployment"}
+	if got := s.loginKeys(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected logins with %v, got %v", want, got)
+	}
+}
+
+func TestNuvlaDeploymentClient_UpdateParameterCreatesMissing(t *testing.T) {
+	tests := []struct {
+		name         string
+		searchStatus int
+		wantCreated  bool
+		wantErr      error
+	}{
+		{name: "missing parameter is created", searchStatus: http.StatusOK, wantCreated: true},
+		{name: "search error is returned", searchStatus: http.StatusForbidden, wantErr: types.ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var created []map[string]interface{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.Method == http.MethodPost && r.URL.Path == "/api/deployment-parameter" {
+					var body map[string]interface{}
+					_ = json.NewDecoder(r.Body).Decode(&body)
+					created = append(created, body)
+					w.WriteHeader(http.StatusCreated)
+					_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 201, "resource-id": "deployment-parameter/1"})
+					return
+				}
+				w.WriteHeader(tt.searchStatus)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": 0, "resources": []interface{}{}})
+			}))
+			defer srv.Close()
+
+			c := nuvla.NewNuvlaClientFromOpts(nil, nuvla.WithEndpoint(srv.URL), nuvla.WithoutPersistCookie)
+			dc := NewNuvlaDeploymentClient("deployment/1", c)
+			err := dc.UpdateParameter(context.Background(), "user/1",
+				resources.WithParent("deployment/1"), resources.WithName("ip"), resources.WithValue("10.0.0.5"))
+
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+			if created := len(created) == 1; created != tt.wantCreated {
+				t.Fatalf("expected parameter created: %t, got %t", tt.wantCreated, created)
+			}
+			if tt.wantCreated && (created[0]["name"] != "ip" || created[0]["value"] != "10.0.0.5") {
+				t.Errorf("unexpected parameter created: %v", created[0])
+			}
+		})
+	}
+}
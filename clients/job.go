@@ -6,17 +6,20 @@ import (
 	"errors"
 	nuvla "github.com/nuvla/api-client-go"
 	"github.com/nuvla/api-client-go/clients/resources"
+	"github.com/nuvla/api-client-go/common"
 	"github.com/nuvla/api-client-go/types"
-	log "github.com/sirupsen/logrus"
-	"io"
-	"net/http"
+	"sync"
 )
 
+// NuvlaJobClient is safe for concurrent use. The resource returned by GetResource must not be modified.
 type NuvlaJobClient struct {
 	*nuvla.NuvlaClient
 
-	jobId       *types.NuvlaID
-	jobResource *resources.JobResource
+	jobId *types.NuvlaID
+	// mu guards jobResource
+	mu             sync.RWMutex
+	jobResource    *resources.JobResource
+	resourceClient *ResourceClient[*resources.JobResource]
 }
 
 func NewJobClient(jobId string, client *nuvla.NuvlaClient) *NuvlaJobClient {
@@ -24,37 +27,26 @@ func NewJobClient(jobId string, client *nuvla.NuvlaClient) *NuvlaJobClient {
 		panic("Client should not be nil")
 	}
 
-	log.Infof("Job client Endpoint: %s", client.String())
+	client.Logger().Debugf("Job client Endpoint: %s", client.String())
 	return &NuvlaJobClient{
-		NuvlaClient: client,
-		jobId:       types.NewNuvlaIDFromId(jobId),
-		jobResource: &resources.JobResource{},
+		NuvlaClient:    client,
+		jobId:          types.NewNuvlaIDFromId(jobId),
+		jobResource:    &resources.JobResource{},
+		resourceClient: NewResourceClient[*resources.JobResource](client, resources.JobType),
 	}
 }
 
 func (jc *NuvlaJobClient) UpdateResource(ctx context.Context) error {
-	res, err := jc.Get(ctx, jc.jobId.Id, nil)
+	res, err := jc.resourceClient.Get(ctx, jc.jobId.Id, nil)
 	if err != nil {
-		log.Infof("Error updating Deployment resource %s", jc.jobId)
-		return nil
-	}
-
-	if jc.jobResource == nil {
-		jc.jobResource = &resources.JobResource{}
-	}
-
-	b, err := json.Marshal(res.Data)
-	if err != nil {
-		log.Errorf("Error marshaling deployment resource response data to bytes")
+		jc.Logger().Infof("Error updating Job resource %s: %s", jc.jobId, err)
 		return err
 	}
 
-	err = json.Unmarshal(b, jc.jobResource)
-	if err != nil {
-		log.Error("Error unmarshalling response into DeploymentResource structure")
-		return err
-	}
-	log.Infof("Successfully updated deployment resource")
+	jc.mu.Lock()
+	jc.jobResource = res
+	jc.mu.Unlock()
+	jc.Logger().Debugf("Successfully updated job resource")
 	return nil
 }
 
@@ -67,61 +59,82 @@ func (jc *NuvlaJobClient) GetType() resources.NuvlaResourceType {
 }
 
 func (jc *NuvlaJobClient) GetResourceMap() (map[string]interface{}, error) {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
+
 	var mapRes map[string]interface{}
 	if err := MarshalResourceIntoMap(jc.jobResource, mapRes); err != nil {
-		log.Errorf("Error marshaling DeploymentResource to map")
+		jc.Logger().Errorf("Error marshaling DeploymentResource to map")
 		return nil, err
 	}
 	return mapRes, nil
 }
 
 func (jc *NuvlaJobClient) GetActionName() string {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
 	return jc.jobResource.Action
 }
 
 func (jc *NuvlaJobClient) GetResource() *resources.JobResource {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
 	return jc.jobResource
 }
 
+// updateResource applies fn to a copy of the job resource, so the resources already returned by GetResource
+// never change
+func (jc *NuvlaJobClient) updateResource(fn func(jr *resources.JobResource)) {
+	jc.mu.Lock()
+	defer jc.mu.Unlock()
+	updated := *jc.jobResource
+	fn(&updated)
+	jc.jobResource = &updated
+}
+
 func (jc *NuvlaJobClient) PrintResource() {
+	jc.mu.RLock()
+	defer jc.mu.RUnlock()
 	if jc.jobResource == nil {
-		log.Debugf("Resource empty")
+		jc.Logger().Debugf("Resource empty")
 		return
 	}
 
 	p, err := json.MarshalIndent(jc.jobResource, "", "  ")
 	if err != nil {
-		log.Debugf("Error Marshaling %s resource, cannot print", jc.GetType())
+		jc.Logger().Debugf("Error Marshaling %s resource, cannot print", jc.GetType())
 		return
 	}
 
-	log.Infof("%s resource: \n %s", jc.GetType(), string(p))
+	jc.Logger().Infof("%s resource: \n %s", jc.GetType(), string(p))
 }
 
 func (jc *NuvlaJobClient) UpdateJobStatus(ctx context.Context, opts JobStatusUpdateOpts) error {
 	res, err := jc.Edit(ctx, jc.jobId.Id, opts.GetMap(), nil)
 	if err != nil {
-		log.Errorf("Error updating job status: %s", err)
+		jc.Logger().Errorf("Error updating job status: %s", err)
 		return err
 	}
-	PrintResponse(res)
-	opts.UpdateJobResource(jc.jobResource)
+	logResponse(jc.Logger(), res)
+	jc.updateResource(opts.UpdateJobResource)
 	return nil
 }
 
 func (jc *NuvlaJobClient) SetProgress(ctx context.Context, progress int8) error {
 	if progress < 0 || progress > 100 {
-		log.Errorf("Progress value %d is not valid", progress)
+		jc.Logger().Errorf("Progress value %d is not valid", progress)
 		return nil
 	}
-	log.Debugf("Setting progress in %s to %d", jc.jobId.Id, progress)
+	jc.Logger().Debugf("Setting progress in %s to %d", jc.jobId.Id, progress)
 	res, err := jc.Edit(ctx, jc.jobId.Id, map[string]interface{}{"progress": progress}, nil)
 	if err != nil {
-		log.Errorf("Error setting progress to %d: %s", progress, err)
+		jc.Logger().Errorf("Error setting progress to %d: %s", progress, err)
 		return err
 	}
-	PrintResponse(res)
-	jc.jobResource.Progress = progress
+	logResponse(jc.Logger(), res)
+	jc.updateResource(func(jr *resources.JobResource) {
+		jr.Progress = progress
+	})
 	return nil
 }
 
@@ -129,50 +142,55 @@ func (jc *NuvlaJobClient) SetProgress(ctx context.Context, progress int8) error
 func (jc *NuvlaJobClient) SetStatusMessage(ctx context.Context, message string) {
 	res, err := jc.Edit(ctx, jc.jobId.Id, map[string]interface{}{"status-message": message}, nil)
 	if err != nil {
-		log.Errorf("Error setting status message %s: %s", message, err)
+		jc.Logger().Errorf("Error setting status message %s: %s", message, err)
 		return
 	}
-	PrintResponse(res)
-	jc.jobResource.StatusMessage = message
+	logResponse(jc.Logger(), res)
+	jc.updateResource(func(jr *resources.JobResource) {
+		jr.StatusMessage = message
+	})
 }
 
 // SetState
 func (jc *NuvlaJobClient) SetState(ctx context.Context, state resources.JobState) {
 	res, err := jc.Edit(ctx, jc.jobId.Id, map[string]interface{}{"state": state}, nil)
 	if err != nil {
-		log.Error("Error setting state %s", state)
+		jc.Logger().Errorf("Error setting state %s: %s", state, err)
+		return
 	}
-	PrintResponse(res)
-	jc.jobResource.State = state
+	logResponse(jc.Logger(), res)
+	jc.updateResource(func(jr *resources.JobResource) {
+		jr.State = state
+	})
 }
 
 // SetInitialState sets both the state to RUNNING and the progress to 10
 func (jc *NuvlaJobClient) SetInitialState(ctx context.Context) {
-	log.Infof("Setting initial processing state...")
+	jc.Logger().Infof("Setting initial processing state...")
 	res, err := jc.Edit(ctx, jc.jobId.Id, map[string]interface{}{"state": resources.StateRUNNING, "progress": 10}, nil)
 	if err != nil {
-		log.Errorf("Error setting initial state %s", err)
+		jc.Logger().Errorf("Error setting initial state %s", err)
 		return
 	}
-	PrintResponse(res)
-	log.Infof("Setting initial processing state... Success.")
+	logResponse(jc.Logger(), res)
+	jc.Logger().Infof("Setting initial processing state... Success.")
 }
 
 // SetSuccessState sets the state to SUCCESS and the progress to 100
 func (jc *NuvlaJobClient) SetSuccessState(ctx context.Context) {
-	log.Debugf("Setting success state...")
+	jc.Logger().Debugf("Setting success state...")
 	res, err := jc.Edit(ctx, jc.jobId.Id, map[string]interface{}{"state": resources.StateSuccess, "progress": 100}, nil)
 	if err != nil {
-		log.Errorf("Error setting success state %s", err)
+		jc.Logger().Errorf("Error setting success state %s", err)
 		return
 	}
-	PrintResponse(res)
-	log.Debugf("Setting success state... Success.")
+	logResponse(jc.Logger(), res)
+	jc.Logger().Debugf("Setting success state... Success.")
 }
 
 // SetFailedState sets the state to FAILED and the progress to 100
 func (jc *NuvlaJobClient) SetFailedState(ctx context.Context, errMsg string) {
-	log.Debugf("Setting failed state...")
+	jc.Logger().Debugf("Setting failed state...")
 	opts := JobStatusUpdateOpts{
 		Progress:      100,
 		StatusMessage: errMsg,
@@ -180,14 +198,18 @@ func (jc *NuvlaJobClient) SetFailedState(ctx context.Context, errMsg string) {
 	}
 	err := jc.UpdateJobStatus(ctx, opts)
 	if err != nil {
-		log.Errorf("Error setting failed state %s", err)
+		jc.Logger().Errorf("Error setting failed state %s", err)
 		return
 	}
-	log.Debugf("Setting failed state... Success.")
+	jc.Logger().Debugf("Setting failed state... Success.")
 }
 
 func (jc *NuvlaJobClient) GetCredentials() (string, string, error) {
-	creds := jc.Credentials.GetParams()
+	current := jc.CurrentCredentials()
+	if common.IsNilValueInterface(current) {
+		return "", "", errors.New("no credentials available")
+	}
+	creds := current.GetParams()
 	k, ok := creds["key"]
 	if !ok {
 		return "", "", errors.New("key not found in credentials")
@@ -199,28 +221,19 @@ func (jc *NuvlaJobClient) GetCredentials() (string, string, error) {
 	return k, s, nil
 }
 
-func PrintResponse(res *http.Response) {
-
-	log.Infof("Processing response with jobs...")
-	body, err := io.ReadAll(res.Body)
-	defer res.Body.Close()
-	if err != nil {
-		log.Errorf("Error reading response body: %s", err)
-		return
-	}
+// PrintResponse logs the message and the jobs created by a Nuvla response with the default logger. The clients
+// log their responses with their own logger.
+func PrintResponse(res *types.NuvlaResponse) {
+	logResponse(common.DefaultLogger(), res)
+}
 
-	var sample struct {
-		Message string   `json:"message"`
-		Jobs    []string `json:"jobs"`
-	}
-	err = json.Unmarshal(body, &sample)
-	if err != nil {
-		log.Errorf("Error unmarshaling response body: %s", err)
+// logResponse logs the message and the jobs created by a Nuvla response
+func logResponse(log *common.PrintfLogger, res *types.NuvlaResponse) {
+	if res == nil {
 		return
 	}
-
-	bytes, _ := json.MarshalIndent(sample, "", "  ")
-	log.Debugf("Setting progress response %s", string(bytes))
+	log.Debugf("Processing response with jobs...")
+	log.Debugf("Response status %d: %s. Jobs: %v", res.Status, res.Message, res.GetJobIds())
 }
 
 type JobStatusUpdateOpts struct {
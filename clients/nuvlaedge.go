@@ -2,15 +2,13 @@ package clients
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	nuvla "github.com/nuvla/api-client-go"
 	"github.com/nuvla/api-client-go/clients/resources"
 	"github.com/nuvla/api-client-go/common"
 	"github.com/nuvla/api-client-go/types"
-	log "github.com/sirupsen/logrus"
-	"io"
 	"net/http"
+	"sync"
 )
 
 type NuvlaEdgeSessionFreeze struct {
@@ -41,13 +39,15 @@ func (sf *NuvlaEdgeSessionFreeze) Save(file string) error {
 
 	err := common.WriteIndentedJSONToFile(sf, file)
 	if err != nil {
-		log.Errorf("Error saving NuvlaEdgeSessionFreeze: %s", err)
+		common.DefaultLogger().Errorf("Error saving NuvlaEdgeSessionFreeze: %s", err)
 		return err
 	}
 
 	return nil
 }
 
+// NuvlaEdgeClient is safe for concurrent use, e.g. sending heartbeats and telemetry from different goroutines.
+// NuvlaEdgeStatusId is updated by Commission and Telemetry: use GetNuvlaEdgeStatusId to read it concurrently.
 type NuvlaEdgeClient struct {
 	*nuvla.NuvlaClient
 
@@ -56,7 +56,12 @@ type NuvlaEdgeClient struct {
 	CredentialId      *types.NuvlaID
 	Irs               string
 
+	// mu guards nuvlaEdgeResource and NuvlaEdgeStatusId
+	mu sync.RWMutex
+	// updateMu serialises the updates of nuvlaEdgeResource, so a partial update never overwrites a newer one
+	updateMu          sync.Mutex
 	nuvlaEdgeResource *resources.NuvlaEdgeResource
+	resourceClient    *ResourceClient[*resources.NuvlaEdgeResource]
 }
 
 func NewNuvlaEdgeClient(nuvlaEdgeId string, credentials *types.ApiKeyLogInParams, opts ...nuvla.SessionOptFunc) *NuvlaEdgeClient {
@@ -64,85 +69,68 @@ func NewNuvlaEdgeClient(nuvlaEdgeId string, credentials *types.ApiKeyLogInParams
 	for _, fn := range opts {
 		fn(sessionOpts)
 	}
-	log.Infof("Creating NuvlaEdge client with options: %v", sessionOpts)
 
 	ne := &NuvlaEdgeClient{
 		NuvlaClient: nuvla.NewNuvlaClient(credentials, sessionOpts),
 		NuvlaEdgeId: types.NewNuvlaIDFromId(nuvlaEdgeId),
 	}
+	ne.resourceClient = NewResourceClient[*resources.NuvlaEdgeResource](ne.NuvlaClient, resources.NuvlaBoxType)
+	// The session options hold credentials: only log where the client connects to
+	ne.Logger().Infof("Created NuvlaEdge client %s for %s", nuvlaEdgeId, sessionOpts.Endpoint)
 	return ne
 }
 
 func NewNuvlaEdgeClientFromSessionFreeze(f *NuvlaEdgeSessionFreeze) *NuvlaEdgeClient {
-	log.Infof("Creating NuvlaEdge client from session freeze")
 	ne := &NuvlaEdgeClient{}
 
 	ne.NuvlaEdgeId = types.NewNuvlaIDFromId(f.NuvlaEdgeId)
 	ne.NuvlaEdgeStatusId = types.NewNuvlaIDFromId(f.NuvlaEdgeStatusId)
-	ne.Credentials = f.Credentials
 
 	// Create NuvlaClient
-	ne.NuvlaClient = nuvla.NewNuvlaClient(ne.Credentials, &f.SessionOptions)
+	ne.NuvlaClient = nuvla.NewNuvlaClient(f.Credentials, &f.SessionOptions)
+	ne.resourceClient = NewResourceClient[*resources.NuvlaEdgeResource](ne.NuvlaClient, resources.NuvlaBoxType)
+	ne.Logger().Infof("Created NuvlaEdge client %s from session freeze", f.NuvlaEdgeId)
 
 	return ne
 }
 
-func extractCredentialsFromActivateResponse(resp *http.Response) (*types.ApiKeyLogInParams, error) {
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if err != nil {
-		log.Errorf("Error reading response body: %s", err)
-		return nil, err
-	}
-
-	c := make(map[string]string)
-	err = json.Unmarshal(body, &c)
+func extractCredentialsFromActivateResponse(res *types.NuvlaResponse) (*types.ApiKeyLogInParams, error) {
 	creds := &types.ApiKeyLogInParams{}
-	k, ok := c["api-key"]
+	k, ok := res.Data["api-key"].(string)
 	if !ok {
 		return nil, fmt.Errorf("api-key not found in response")
 	}
 	creds.Key = k
 
-	s, ok := c["secret-key"]
+	s, ok := res.Data["secret-key"].(string)
 	if !ok {
 		return nil, fmt.Errorf("secret-key not found in response")
 	}
 	creds.Secret = s
 
-	if err != nil {
-		log.Errorf("Error unmarshaling response body: %s", err)
-		return nil, err
-	}
-
 	return creds, nil
 }
 
 // LogIn operation
-func (ne *NuvlaEdgeClient) LogIn(creds types.ApiKeyLogInParams) error {
-	err := ne.LoginApiKeys(creds.Key, creds.Secret)
+func (ne *NuvlaEdgeClient) LogIn(ctx context.Context, creds types.ApiKeyLogInParams) error {
+	err := ne.LoginApiKeys(ctx, creds.Key, creds.Secret)
 	if err != nil {
-		log.Errorf("Error logging in with api keys: %s", err)
+		ne.Logger().Errorf("Error logging in with api keys: %s", err)
 		return err
 	}
 
-	log.Infof("Logging in with api keys... Success.")
+	ne.Logger().Infof("Logging in with api keys... Success.")
 	return nil
 }
 
 // Activate Operation
 func (ne *NuvlaEdgeClient) Activate(ctx context.Context) (types.ApiKeyLogInParams, error) {
-	log.Infof("Activating NuvlaEdge...%v", ne.NuvlaEdgeId)
+	ne.Logger().Infof("Activating NuvlaEdge...%v", ne.NuvlaEdgeId)
 	res, err := ne.Operation(ctx, ne.NuvlaEdgeId.String(), "activate", nil)
 	if err != nil {
-		log.Errorf("Error activating NuvlaEdge: %s", err)
+		ne.Logger().Errorf("Error activating NuvlaEdge: %s", err)
 		return types.ApiKeyLogInParams{}, err
 	}
-	if res.StatusCode != 200 && res.StatusCode != 201 {
-		return types.ApiKeyLogInParams{}, fmt.Errorf("activation failed with status code: %v", res.StatusCode)
-	}
 
 	creds, err := extractCredentialsFromActivateResponse(res)
 	if err != nil {
@@ -154,72 +142,82 @@ func (ne *NuvlaEdgeClient) Activate(ctx context.Context) (types.ApiKeyLogInParam
 
 // Commission operations
 func (ne *NuvlaEdgeClient) Commission(ctx context.Context, data map[string]interface{}) error {
-	log.Debugf("Commissioning NuvlaEdge with payload %v", data)
-	res, err := ne.Operation(ctx, ne.NuvlaEdgeId.String(), "commission", data)
+	ne.Logger().Debugf("Commissioning NuvlaEdge with payload %v", data)
+	_, err := ne.Operation(ctx, ne.NuvlaEdgeId.String(), "commission", data)
 	if err != nil {
-		log.Errorf("Error commissioning NuvlaEdge: %s", err)
+		ne.Logger().Errorf("Error commissioning NuvlaEdge: %s", err)
 		return err
 	}
 
-	err = res.Body.Close()
-	if err != nil {
-		log.Errorf("Error closing commission response body: %s", err)
-	}
-
-	if res.StatusCode != 200 && res.StatusCode != 201 {
-		return fmt.Errorf("commissioning failed with status code: %v", res.StatusCode)
-	}
-
-	log.Debug("Updating NuvlaEdge resource...")
+	ne.Logger().Debug("Updating NuvlaEdge resource...")
 	err = ne.UpdateResource(ctx)
 	if err != nil {
-		log.Errorf("Error getting NuvlaEdge resource: %s", err)
+		ne.Logger().Errorf("Error getting NuvlaEdge resource: %s", err)
 		return err
 	}
-	log.Debug("Updating NuvlaEdge resource... Success")
+	ne.Logger().Debug("Updating NuvlaEdge resource... Success")
 
-	if ne.nuvlaEdgeResource.NuvlaBoxStatus != "" {
-		ne.NuvlaEdgeStatusId = types.NewNuvlaIDFromId(ne.nuvlaEdgeResource.NuvlaBoxStatus)
-	} else {
+	if ne.setStatusIdFromResource() == nil {
 		return fmt.Errorf("nuvlabox-status not found in resource")
 	}
 	return nil
 }
 
+// GetNuvlaEdgeStatusId returns the ID of the nuvlabox-status resource, or nil if it is not known yet
+func (ne *NuvlaEdgeClient) GetNuvlaEdgeStatusId() *types.NuvlaID {
+	ne.mu.RLock()
+	defer ne.mu.RUnlock()
+	if ne.NuvlaEdgeStatusId == nil || ne.NuvlaEdgeStatusId.Id == "" {
+		return nil
+	}
+	return ne.NuvlaEdgeStatusId
+}
+
+// setStatusIdFromResource sets NuvlaEdgeStatusId from the nuvlabox-status of the resource and returns it
+func (ne *NuvlaEdgeClient) setStatusIdFromResource() *types.NuvlaID {
+	ne.mu.Lock()
+	defer ne.mu.Unlock()
+	if ne.nuvlaEdgeResource == nil || ne.nuvlaEdgeResource.NuvlaBoxStatus == "" {
+		return nil
+	}
+	ne.NuvlaEdgeStatusId = types.NewNuvlaIDFromId(ne.nuvlaEdgeResource.NuvlaBoxStatus)
+	return ne.NuvlaEdgeStatusId
+}
+
 // Telemetry operation
-func (ne *NuvlaEdgeClient) Telemetry(ctx context.Context, data interface{}, Select []string) (*http.Response, error) {
-	log.Debugf("Sending telemetry data to NuvlaEdge with payload %v", data)
-	if ne.nuvlaEdgeResource.NuvlaBoxStatus == "" || ne.NuvlaEdgeStatusId == nil {
+func (ne *NuvlaEdgeClient) Telemetry(ctx context.Context, data interface{}, Select []string, opts ...nuvla.CallOption) (*http.Response, error) {
+	ne.Logger().Debugf("Sending telemetry data to NuvlaEdge with payload %v", data)
+	statusId := ne.GetNuvlaEdgeStatusId()
+	if statusId == nil {
 		err := ne.UpdateResourceSelect(ctx, []string{"nuvlabox-status"})
 		if err != nil {
-			log.Errorf("Error sending Telemetry, cannot find NuvlaBoxStatus ID: %s", err)
+			ne.Logger().Errorf("Error sending Telemetry, cannot find NuvlaBoxStatus ID: %s", err)
 			return nil, err
 		}
-		ne.NuvlaEdgeStatusId = types.NewNuvlaIDFromId(ne.nuvlaEdgeResource.NuvlaBoxStatus)
+		if statusId = ne.setStatusIdFromResource(); statusId == nil {
+			return nil, fmt.Errorf("nuvlabox-status not found in resource")
+		}
 	}
 
-	res, err := ne.Put(ctx, ne.NuvlaEdgeStatusId.String(), data, Select)
+	res, err := ne.Put(ctx, statusId.String(), data, Select, opts...)
 	if err != nil {
-		log.Errorf("Error sending telemetry data to Nuvla: %s", err)
+		ne.Logger().Errorf("Error sending telemetry data to Nuvla: %s", err)
 		return nil, err
 	}
 	return res, nil
 }
 
-// Heartbeat operation
-func (ne *NuvlaEdgeClient) Heartbeat(ctx context.Context) (*http.Response, error) {
-	log.Debug("Sending heartbeat to NuvlaEdge...")
+// Heartbeat operation. The response Data field contains the heartbeat document, including the pending jobs.
+func (ne *NuvlaEdgeClient) Heartbeat(ctx context.Context, opts ...nuvla.CallOption) (*types.NuvlaResponse, error) {
+	ne.Logger().Debug("Sending heartbeat to NuvlaEdge...")
 
-	res, err := ne.Operation(ctx, ne.NuvlaEdgeId.String(), "heartbeat", nil)
+	res, err := ne.Operation(ctx, ne.NuvlaEdgeId.String(), "heartbeat", nil, opts...)
 	if err != nil {
-		log.Errorf("Error sending heartbeat to NuvlaEdge: %s", err)
+		ne.Logger().Errorf("Error sending heartbeat to NuvlaEdge: %s", err)
 		return nil, err
 	}
-	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
-		return nil, fmt.Errorf("heartbeat failed with status code: %v", res.StatusCode)
-	}
 
-	log.Debug("Sending heartbeat to NuvlaEdge... Success.")
+	ne.Logger().Debug("Sending heartbeat to NuvlaEdge... Success.")
 	return res, nil
 }
 
@@ -232,37 +230,32 @@ func (ne *NuvlaEdgeClient) GetType() resources.NuvlaResourceType {
 }
 
 func (ne *NuvlaEdgeClient) GetResourceMap() (map[string]interface{}, error) {
+	ne.mu.RLock()
+	defer ne.mu.RUnlock()
+
 	var mapRes map[string]interface{}
 	if err := MarshalResourceIntoMap(ne.nuvlaEdgeResource, mapRes); err != nil {
-		log.Errorf("Error marshaling NuvlaEdgeResource to map")
+		ne.Logger().Errorf("Error marshaling NuvlaEdgeResource to map")
 		return nil, err
 	}
 	return mapRes, nil
 }
 
 func (ne *NuvlaEdgeClient) UpdateResourceSelect(ctx context.Context, selects []string) error {
-	res, err := ne.Get(ctx, ne.NuvlaEdgeId.Id, selects)
-	if err != nil {
-		log.Infof("Error updating NuvlaEdge resource %s", ne.NuvlaEdgeId)
-		return nil
-	}
-
-	if ne.nuvlaEdgeResource == nil {
-		ne.nuvlaEdgeResource = &resources.NuvlaEdgeResource{}
-	}
+	ne.updateMu.Lock()
+	defer ne.updateMu.Unlock()
 
-	b, err := json.Marshal(res.Data)
-	if err != nil {
-		log.Errorf("Error marshaling NuvlaEdge resource response data to bytes")
+	// Decode into a copy, so readers never see a half decoded resource
+	updated := ne.GetNuvlaEdgeResource()
+	if err := ne.resourceClient.GetInto(ctx, ne.NuvlaEdgeId.Id, selects, &updated); err != nil {
+		ne.Logger().Infof("Error updating NuvlaEdge resource %s: %s", ne.NuvlaEdgeId, err)
 		return err
 	}
 
-	err = json.Unmarshal(b, ne.nuvlaEdgeResource)
-	if err != nil {
-		log.Error("Error unmarshalling response into NuvlaEdgeResource structure")
-		return err
-	}
-	log.Infof("Successfully updated NuvlaEdge resource")
+	ne.mu.Lock()
+	ne.nuvlaEdgeResource = &updated
+	ne.mu.Unlock()
+	ne.Logger().Debugf("Successfully updated NuvlaEdge resource")
 	return nil
 }
 
@@ -271,8 +264,10 @@ func (ne *NuvlaEdgeClient) UpdateResource(ctx context.Context) error {
 }
 
 func (ne *NuvlaEdgeClient) GetNuvlaEdgeResource() resources.NuvlaEdgeResource {
+	ne.mu.RLock()
+	defer ne.mu.RUnlock()
 	if ne.nuvlaEdgeResource == nil {
-		ne.nuvlaEdgeResource = &resources.NuvlaEdgeResource{}
+		return resources.NuvlaEdgeResource{}
 	}
 	return *ne.nuvlaEdgeResource
 }
@@ -282,7 +277,7 @@ func (ne *NuvlaEdgeClient) GetNuvlaClient() *nuvla.NuvlaClient {
 }
 
 func (ne *NuvlaEdgeClient) Freeze(file string) error {
-	log.Infof("Freezing NuvlaEdge client...")
+	ne.Logger().Infof("Freezing NuvlaEdge client...")
 
 	f := NuvlaEdgeSessionFreeze{
 		SessionOptions: ne.GetSessionOpts(),
@@ -293,12 +288,13 @@ func (ne *NuvlaEdgeClient) Freeze(file string) error {
 	}
 
 	// Keep credentials if available for backwards compatibility
-	c, ok := ne.Credentials.(*types.ApiKeyLogInParams)
+	c, ok := ne.CurrentCredentials().(*types.ApiKeyLogInParams)
 	if ok {
 
 		f.Credentials = c
 	}
 
+	ne.mu.RLock()
 	if ne.nuvlaEdgeResource != nil {
 		f.InfraServiceId = ne.nuvlaEdgeResource.InfrastructureServiceGroup
 	}
@@ -306,6 +302,7 @@ func (ne *NuvlaEdgeClient) Freeze(file string) error {
 	if ne.NuvlaEdgeStatusId != nil {
 		f.NuvlaEdgeStatusId = ne.NuvlaEdgeStatusId.String()
 	}
+	ne.mu.RUnlock()
 
 	return f.Save(file)
 }
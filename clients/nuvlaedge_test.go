@@ -1 +1,66 @@
 package clients
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	nuvla "github.com/nuvla/api-client-go"
+)
+
+func TestNuvlaEdgeClient_ConcurrentHeartbeatAndTelemetry(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/nuvlabox/1":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"id":              "nuvlabox/1",
+				"nuvlabox-status": "nuvlabox-status/1",
+			})
+		default:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "message": "ok"})
+		}
+	}))
+	defer srv.Close()
+
+	ne := NewNuvlaEdgeClient("nuvlabox/1", nil, nuvla.WithEndpoint(srv.URL), nuvla.WithoutPersistCookie)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			_, err := ne.Heartbeat(ctx)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			r, err := ne.Telemetry(ctx, map[string]interface{}{"status": "OPERATIONAL"}, nil)
+			if err == nil {
+				_ = r.Body.Close()
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- ne.UpdateResource(ctx)
+			_ = ne.GetNuvlaEdgeResource()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("concurrent request failed: %s", err)
+		}
+	}
+	if id := ne.GetNuvlaEdgeStatusId(); id == nil || id.Id != "nuvlabox-status/1" {
+		t.Errorf("unexpected nuvlabox-status id %v", id)
+	}
+}
@@ -2,23 +2,23 @@ package clients
 
 import (
 	"encoding/json"
-	log "github.com/sirupsen/logrus"
+	"github.com/nuvla/api-client-go/common"
 )
 
 func MarshalResourceIntoMap(resource interface{}, resMap map[string]interface{}) error {
 	var mapRes map[string]interface{}
 	b, err := json.Marshal(resource)
 	if err != nil {
-		log.Error("Error marshaling resource to bytes")
+		common.DefaultLogger().Error("Error marshaling resource to bytes")
 		return err
 	}
 
 	err = json.Unmarshal(b, &mapRes)
 	if err != nil {
-		log.Error("Error unmarshalling resource to map")
+		common.DefaultLogger().Error("Error unmarshalling resource to map")
 		return err
 	}
 
-	log.Debug("Successfully marshaled resource into map")
+	common.DefaultLogger().Debug("Successfully marshaled resource into map")
 	return nil
 }
@@ -0,0 +1,140 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	nuvla "github.com/nuvla/api-client-go"
+	"github.com/nuvla/api-client-go/clients/resources"
+	"github.com/nuvla/api-client-go/types"
+)
+
+// ResourceList is a typed page of resources returned by ResourceClient.Search
+type ResourceList[T resources.NuvlaResource] struct {
+	Resources []T
+	// Count is the total number of resources matching the search, regardless of pagination
+	Count int
+	// Aggregations holds the results of the aggregations requested in the search options
+	Aggregations resources.Aggregations
+}
+
+// ResourceClient is a typed client bound to a single Nuvla resource collection.
+// T is the pointer to the resource struct, e.g. *resources.NuvlaEdgeResource.
+type ResourceClient[T resources.NuvlaResource] struct {
+	client       *nuvla.NuvlaClient
+	resourceType resources.NuvlaResourceType
+}
+
+func NewResourceClient[T resources.NuvlaResource](client *nuvla.NuvlaClient, resourceType resources.NuvlaResourceType) *ResourceClient[T] {
+	return &ResourceClient[T]{
+		client:       client,
+		resourceType: resourceType,
+	}
+}
+
+func (rc *ResourceClient[T]) GetType() resources.NuvlaResourceType {
+	return rc.resourceType
+}
+
+func (rc *ResourceClient[T]) newResource() T {
+	var zero T
+	return zero.New().(T)
+}
+
+// Fields returns the select list derived from the JSON tags of T
+func (rc *ResourceClient[T]) Fields() []string {
+	return resources.SelectFieldsFromStruct(rc.newResource())
+}
+
+// Get retrieves the resource with the given ID. Pass Fields() as selectFields to only retrieve the attributes T holds.
+func (rc *ResourceClient[T]) Get(ctx context.Context, id string, selectFields []string, opts ...nuvla.CallOption) (T, error) {
+	res := rc.newResource()
+	if err := rc.GetInto(ctx, id, selectFields, res, opts...); err != nil {
+		var zero T
+		return zero, err
+	}
+	return res, nil
+}
+
+// GetInto retrieves the resource with the given ID and decodes it into an existing resource. Attributes not
+// returned by the server, e.g. because of selectFields, keep their current value.
+func (rc *ResourceClient[T]) GetInto(ctx context.Context, id string, selectFields []string, resource T, opts ...nuvla.CallOption) error {
+	res, err := rc.client.Get(ctx, id, selectFields, opts...)
+	if err != nil {
+		return err
+	}
+	if res == nil {
+		return fmt.Errorf("error decoding %s resource %s", rc.resourceType, id)
+	}
+	return resources.NewResourceFromMap(res.Data, resource)
+}
+
+// Search returns the resources of the collection matching the search options
+func (rc *ResourceClient[T]) Search(ctx context.Context, opts *nuvla.SearchOptions, callOpts ...nuvla.CallOption) (*ResourceList[T], error) {
+	if opts == nil {
+		opts = nuvla.NewDefaultSearchOptions()
+	}
+	collection, err := rc.client.Search(ctx, string(rc.resourceType), opts, callOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ResourceList[T]{
+		Resources:    make([]T, 0, len(collection.Resources)),
+		Count:        collection.Count,
+		Aggregations: collection.Aggregations,
+	}
+	for _, m := range collection.Resources {
+		res := rc.newResource()
+		if err := resources.NewResourceFromMap(m, res); err != nil {
+			rc.client.Logger().Errorf("Error decoding %s resource: %s", rc.resourceType, err)
+			return nil, err
+		}
+		list.Resources = append(list.Resources, res)
+	}
+	return list, nil
+}
+
+// Add creates a new resource in the collection
+func (rc *ResourceClient[T]) Add(ctx context.Context, resource T, opts ...nuvla.CallOption) (*types.NuvlaResponse, error) {
+	data, err := resourceToMap(resource)
+	if err != nil {
+		return nil, err
+	}
+	return rc.client.Add(ctx, rc.resourceType, data, opts...)
+}
+
+// Edit updates the given attributes of the resource and returns the updated resource
+func (rc *ResourceClient[T]) Edit(ctx context.Context, id string, data map[string]interface{}, selectFields []string, opts ...nuvla.CallOption) (T, error) {
+	var zero T
+	res, err := rc.client.Edit(ctx, id, data, selectFields, opts...)
+	if err != nil {
+		return zero, err
+	}
+
+	updated := rc.newResource()
+	if err := resources.NewResourceFromMap(res.Data, updated); err != nil {
+		return zero, err
+	}
+	return updated, nil
+}
+
+func (rc *ResourceClient[T]) Delete(ctx context.Context, id string, opts ...nuvla.CallOption) (*types.NuvlaResponse, error) {
+	return rc.client.Delete(ctx, id, opts...)
+}
+
+func (rc *ResourceClient[T]) Operation(ctx context.Context, id, operation string, data map[string]interface{}, opts ...nuvla.CallOption) (*types.NuvlaResponse, error) {
+	return rc.client.Operation(ctx, id, operation, data, opts...)
+}
+
+func resourceToMap(resource interface{}) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	b, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,177 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	nuvla "github.com/nuvla/api-client-go"
+	"github.com/nuvla/api-client-go/cimi"
+	"github.com/nuvla/api-client-go/clients/resources"
+	"github.com/nuvla/api-client-go/types"
+)
+
+// testCollectionServer serves the nuvlabox collection with two resources and records the requests
+type testCollectionServer struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	selects      [][]string
+	aggregations []string
+	added        []map[string]interface{}
+	edited       []map[string]interface{}
+}
+
+func newTestCollectionServer(t *testing.T) *testCollectionServer {
+	s := &testCollectionServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testCollectionServer) handle(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	edge := map[string]interface{}{"id": "nuvlabox/1", "resource-type": "nuvlabox", "name": "edge", "state": "COMMISSIONED",
+		"refresh-interval": 60, "unknown-attribute": true}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/api/nuvlabox/1":
+		s.selects = append(s.selects, r.URL.Query()["select"])
+		_ = json.NewEncoder(w).Encode(edge)
+	case r.Method == http.MethodGet:
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 404, "message": r.URL.Path + " not found"})
+	case r.Method == http.MethodPut && r.URL.Path == "/api/nuvlabox":
+		_ = r.ParseForm()
+		s.aggregations = append(s.aggregations, r.Form.Get("aggregation"))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"count": 3,
+			"resources": []interface{}{
+				edge,
+				map[string]interface{}{"id": "nuvlabox/2", "state": "NEW"},
+			},
+			"aggregations": map[string]interface{}{
+				"terms:state": map[string]interface{}{"buckets": []interface{}{
+					map[string]interface{}{"key": "COMMISSIONED", "doc_count": 2},
+					map[string]interface{}{"key": "NEW", "doc_count": 1},
+				}},
+			},
+		})
+	case r.Method == http.MethodPut && r.URL.Path == "/api/nuvlabox/1":
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		s.edited = append(s.edited, body)
+		for k, v := range body {
+			edge[k] = v
+		}
+		_ = json.NewEncoder(w).Encode(edge)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/nuvlabox":
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		s.added = append(s.added, body)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 201, "resource-id": "nuvlabox/3"})
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func newTestEdgeResourceClient(s *testCollectionServer) *ResourceClient[*resources.NuvlaEdgeResource] {
+	c := nuvla.NewNuvlaClientFromOpts(nil, nuvla.WithEndpoint(s.URL), nuvla.WithoutPersistCookie)
+	return NewResourceClient[*resources.NuvlaEdgeResource](c, resources.NuvlaBoxType)
+}
+
+func TestResourceClient_Get(t *testing.T) {
+	s := newTestCollectionServer(t)
+	rc := newTestEdgeResourceClient(s)
+	ctx := context.Background()
+
+	edge, err := rc.Get(ctx, "nuvlabox/1", rc.Fields())
+	if err != nil {
+		t.Fatalf("get failed: %s", err)
+	}
+	if edge.Id != "nuvlabox/1" || edge.Name != "edge" || edge.State != "COMMISSIONED" || edge.RefreshInterval != 60 {
+		t.Errorf("unexpected resource %+v", edge)
+	}
+	if len(s.selects) != 1 || len(s.selects[0]) != len(rc.Fields()) {
+		t.Errorf("expected the fields of the resource as select, got %v", s.selects)
+	}
+
+	// Attributes not returned by the server keep their value
+	existing := &resources.NuvlaEdgeResource{Owner: "user/1"}
+	if err := rc.GetInto(ctx, "nuvlabox/1", nil, existing); err != nil {
+		t.Fatalf("get into failed: %s", err)
+	}
+	if existing.Owner != "user/1" || existing.State != "COMMISSIONED" {
+		t.Errorf("unexpected resource %+v", existing)
+	}
+
+	missing, err := rc.Get(ctx, "nuvlabox/404", nil)
+	if !errors.Is(err, types.ErrNotFound) || missing != nil {
+		t.Errorf("expected a nil resource and ErrNotFound, got %v, %v", missing, err)
+	}
+}
+
+func TestResourceClient_Search(t *testing.T) {
+	s := newTestCollectionServer(t)
+	rc := newTestEdgeResourceClient(s)
+
+	opts := nuvla.NewDefaultSearchOptions()
+	opts.Aggregation = cimi.Terms("state").Avg("refresh-interval").String()
+	list, err := rc.Search(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("search failed: %s", err)
+	}
+	if list.Count != 3 || len(list.Resources) != 2 {
+		t.Fatalf("expected 2 of 3 resources, got %d of %d", len(list.Resources), list.Count)
+	}
+	if list.Resources[0].State != "COMMISSIONED" || list.Resources[1].Id != "nuvlabox/2" || list.Resources[1].State != "NEW" {
+		t.Errorf("unexpected resources %+v, %+v", list.Resources[0], list.Resources[1])
+	}
+	if counts := list.Aggregations.TermsCounts("state"); counts["COMMISSIONED"] != 2 || counts["NEW"] != 1 {
+		t.Errorf("unexpected aggregations %v", list.Aggregations)
+	}
+	if len(s.aggregations) != 1 || s.aggregations[0] != "terms:state,avg:refresh-interval" {
+		t.Errorf("expected the aggregation in the search, got %v", s.aggregations)
+	}
+}
+
+func TestResourceClient_AddAndEdit(t *testing.T) {
+	s := newTestCollectionServer(t)
+	rc := newTestEdgeResourceClient(s)
+	ctx := context.Background()
+
+	res, err := rc.Add(ctx, &resources.NuvlaEdgeResource{
+		CommonAttributesResource: resources.CommonAttributesResource{Name: "new-edge"},
+		RefreshInterval:          30,
+	})
+	if err != nil {
+		t.Fatalf("add failed: %s", err)
+	}
+	if id, err := res.GetResourceId(); err != nil || id.Id != "nuvlabox/3" {
+		t.Errorf("expected resource id nuvlabox/3, got %v, %v", id, err)
+	}
+	if len(s.added) != 1 || s.added[0]["name"] != "new-edge" || s.added[0]["refresh-interval"] != 30.0 {
+		t.Errorf("unexpected resource sent %v", s.added)
+	}
+	if _, ok := s.added[0]["id"]; ok {
+		t.Errorf("expected empty optional attributes to be omitted, got %v", s.added[0])
+	}
+
+	edge, err := rc.Edit(ctx, "nuvlabox/1", map[string]interface{}{"name": "renamed"}, nil)
+	if err != nil {
+		t.Fatalf("edit failed: %s", err)
+	}
+	if edge.Name != "renamed" || edge.State != "COMMISSIONED" {
+		t.Errorf("expected the updated resource, got %+v", edge)
+	}
+	if len(s.edited) != 1 || len(s.edited[0]) != 1 {
+		t.Errorf("expected only the edited attributes to be sent, got %v", s.edited)
+	}
+}
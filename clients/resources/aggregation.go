@@ -0,0 +1,81 @@
+package resources
+
+import "fmt"
+
+// AggregationBucket is a single bucket of a terms aggregation
+type AggregationBucket struct {
+	Key         interface{} `json:"key"`
+	KeyAsString string      `json:"key_as_string,omitempty"`
+	DocCount    int         `json:"doc_count"`
+}
+
+// KeyString returns the bucket key as string. Boolean and date keys are returned in their string form.
+func (b AggregationBucket) KeyString() string {
+	if b.KeyAsString != "" {
+		return b.KeyAsString
+	}
+	if s, ok := b.Key.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", b.Key)
+}
+
+// AggregationResult is the result of a single aggregation. Metric aggregations (min, max, avg, sum, value_count
+// and cardinality) set Value, terms aggregations set Buckets.
+type AggregationResult struct {
+	Value            *float64            `json:"value,omitempty"`
+	Buckets          []AggregationBucket `json:"buckets,omitempty"`
+	SumOtherDocCount int                 `json:"sum_other_doc_count,omitempty"`
+}
+
+// Aggregations holds the aggregation results of a search, indexed by "<type>:<attribute>", e.g. "terms:state"
+type Aggregations map[string]AggregationResult
+
+// Terms returns the buckets of the terms aggregation on attribute
+func (a Aggregations) Terms(attribute string) []AggregationBucket {
+	return a["terms:"+attribute].Buckets
+}
+
+// TermsCounts returns the number of resources per value of the terms aggregation on attribute
+func (a Aggregations) TermsCounts(attribute string) map[string]int {
+	buckets := a.Terms(attribute)
+	counts := make(map[string]int, len(buckets))
+	for _, b := range buckets {
+		counts[b.KeyString()] = b.DocCount
+	}
+	return counts
+}
+
+// Value returns the result of a metric aggregation, e.g. Value("avg", "refresh-interval"). The boolean is false
+// if the aggregation was not requested or has no value.
+func (a Aggregations) Value(aggType string, attribute string) (float64, bool) {
+	r, ok := a[aggType+":"+attribute]
+	if !ok || r.Value == nil {
+		return 0, false
+	}
+	return *r.Value, true
+}
+
+func (a Aggregations) ValueCount(attribute string) (float64, bool) {
+	return a.Value("value_count", attribute)
+}
+
+func (a Aggregations) Min(attribute string) (float64, bool) {
+	return a.Value("min", attribute)
+}
+
+func (a Aggregations) Max(attribute string) (float64, bool) {
+	return a.Value("max", attribute)
+}
+
+func (a Aggregations) Avg(attribute string) (float64, bool) {
+	return a.Value("avg", attribute)
+}
+
+func (a Aggregations) Sum(attribute string) (float64, bool) {
+	return a.Value("sum", attribute)
+}
+
+func (a Aggregations) Cardinality(attribute string) (float64, bool) {
+	return a.Value("cardinality", attribute)
+}
@@ -0,0 +1,119 @@
+package resources
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const testAggregationCollection = `{
+	"count": 3,
+	"resources": [],
+	"aggregations": {
+		"terms:state": {
+			"buckets": [{"key": "COMMISSIONED", "doc_count": 2}, {"key": "NEW", "doc_count": 1}],
+			"sum_other_doc_count": 4
+		},
+		"terms:online": {
+			"buckets": [{"key": 1, "key_as_string": "true", "doc_count": 2}, {"key": 0, "key_as_string": "false", "doc_count": 1}]
+		},
+		"terms:refresh-interval": {"buckets": [{"key": 60, "doc_count": 3}]},
+		"avg:refresh-interval": {"value": 45.5},
+		"min:refresh-interval": {"value": 0},
+		"max:refresh-interval": {"value": null},
+		"cardinality:owner": {"value": 2}
+	}
+}`
+
+func TestNewCollectionFromResponse_Aggregations(t *testing.T) {
+	collection, err := NewCollectionFromResponse(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(testAggregationCollection)),
+	})
+	if err != nil {
+		t.Fatalf("error decoding collection: %s", err)
+	}
+	aggs := collection.Aggregations
+
+	state := aggs.Terms("state")
+	if len(state) != 2 || state[0].KeyString() != "COMMISSIONED" || state[0].DocCount != 2 {
+		t.Errorf("unexpected state buckets %+v", state)
+	}
+	if other := aggs["terms:state"].SumOtherDocCount; other != 4 {
+		t.Errorf("expected 4 other documents, got %d", other)
+	}
+
+	tests := []struct {
+		attribute string
+		want      map[string]int
+	}{
+		{"state", map[string]int{"COMMISSIONED": 2, "NEW": 1}},
+		{"online", map[string]int{"true": 2, "false": 1}},
+		{"refresh-interval", map[string]int{"60": 3}},
+		{"missing", map[string]int{}},
+	}
+	for _, tt := range tests {
+		got := aggs.TermsCounts(tt.attribute)
+		if len(got) != len(tt.want) {
+			t.Errorf("TermsCounts(%s) = %v, expected %v", tt.attribute, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("TermsCounts(%s) = %v, expected %v", tt.attribute, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestAggregations_Value(t *testing.T) {
+	collection, err := NewCollectionFromResponse(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(testAggregationCollection)),
+	})
+	if err != nil {
+		t.Fatalf("error decoding collection: %s", err)
+	}
+	aggs := collection.Aggregations
+
+	tests := []struct {
+		name   string
+		get    func() (float64, bool)
+		want   float64
+		wantOk bool
+	}{
+		{"avg", func() (float64, bool) { return aggs.Avg("refresh-interval") }, 45.5, true},
+		{"zero value", func() (float64, bool) { return aggs.Min("refresh-interval") }, 0, true},
+		{"null value", func() (float64, bool) { return aggs.Max("refresh-interval") }, 0, false},
+		{"cardinality", func() (float64, bool) { return aggs.Cardinality("owner") }, 2, true},
+		{"not requested", func() (float64, bool) { return aggs.Sum("refresh-interval") }, 0, false},
+		{"terms has no value", func() (float64, bool) { return aggs.Value("terms", "state") }, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.get()
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("got %v, %t, expected %v, %t", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestAggregationBucket_KeyString(t *testing.T) {
+	tests := []struct {
+		bucket AggregationBucket
+		want   string
+	}{
+		{AggregationBucket{Key: "NEW"}, "NEW"},
+		{AggregationBucket{Key: 1.0, KeyAsString: "true"}, "true"},
+		{AggregationBucket{Key: 1714557600000.0, KeyAsString: "2024-05-01T10:00:00.000Z"}, "2024-05-01T10:00:00.000Z"},
+		{AggregationBucket{Key: 60.0}, "60"},
+		{AggregationBucket{Key: true}, "true"},
+	}
+	for _, tt := range tests {
+		if got := tt.bucket.KeyString(); got != tt.want {
+			t.Errorf("KeyString(%+v) = %s, expected %s", tt.bucket, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package resources
 
 import (
 	"encoding/json"
+	"reflect"
+	"strings"
 	"time"
 )
 
@@ -45,3 +47,47 @@ func NewResourceFromMap(resMap map[string]interface{}, resource NuvlaResource) e
 	}
 	return nil
 }
+
+// SelectFieldsFromStruct returns the attribute names defined by the JSON tags of the resource struct. Fields of
+// embedded structs, such as CommonAttributesResource, are included. The result can be used as select list so that
+// Nuvla only returns the attributes the struct is able to hold.
+func SelectFieldsFromStruct(resource interface{}) []string {
+	t := reflect.TypeOf(resource)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fields []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name := strings.Split(tag, ",")[0]
+
+		if f.Anonymous && name == "" {
+			fields = append(fields, SelectFieldsFromStruct(reflect.New(f.Type).Interface())...)
+			continue
+		}
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if !containsField(fields, name) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+func containsField(fields []string, name string) bool {
+	for _, f := range fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
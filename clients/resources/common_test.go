@@ -0,0 +1,49 @@
+package resources
+
+import (
+	"fmt"
+	"testing"
+)
+
+type selectTestResource struct {
+	CommonAttributesResource
+
+	State    string `json:"state"`
+	Optional string `json:"optional,omitempty"`
+	Untagged string
+	Ignored  string `json:"-"`
+	internal string
+	// Name is also defined by CommonAttributesResource
+	Name string `json:"name"`
+}
+
+func TestSelectFieldsFromStruct(t *testing.T) {
+	common := []string{"id", "resource-type", "created", "updated", "name", "description", "tags", "parent"}
+	tests := []struct {
+		name     string
+		resource interface{}
+		want     []string
+	}{
+		{"embedded and tagged fields", &selectTestResource{}, append(common, "state", "optional", "Untagged")},
+		{"struct value", selectTestResource{}, append(common, "state", "optional", "Untagged")},
+		{"pointer to pointer", func() **CommonAttributesResource { r := &CommonAttributesResource{}; return &r }(), common},
+		{"not a struct", "nuvlabox", nil},
+		{"nil", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SelectFieldsFromStruct(tt.resource); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSelectFieldsFromStruct_Resources(t *testing.T) {
+	fields := SelectFieldsFromStruct(&NuvlaEdgeResource{})
+	for _, want := range []string{"id", "state", "refresh-interval", "vpn-server-id"} {
+		if !containsField(fields, want) {
+			t.Errorf("expected %s in the fields of the nuvlabox resource, got %v", want, fields)
+		}
+	}
+}
@@ -21,7 +21,10 @@ type DeploymentResource struct {
 	ExecutionMode             string                 `json:"execution-mode"`
 	CredentialName            string                 `json:"credential-name"`
 	InfrastructureServiceName string                 `json:"infrastructure-service-name"`
-	Id                        string                 `json:"id"`
+}
+
+func (d *DeploymentResource) New() NuvlaResource {
+	return &DeploymentResource{}
 }
 
 type DeploymentParameterResource struct {
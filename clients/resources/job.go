@@ -11,6 +11,8 @@ const (
 )
 
 type JobResource struct {
+	CommonAttributesResource
+
 	// Required
 	State         JobState `json:"state"`
 	Action        string   `json:"action"`
@@ -37,3 +39,7 @@ type JobResource struct {
 	Output             string `json:"output"`
 	Payload            string `json:"payload"` // JSON-compliant string to be passed to the job, such as execution arguments
 }
+
+func (j *JobResource) New() NuvlaResource {
+	return &JobResource{}
+}
@@ -36,3 +36,7 @@ type NuvlaEdgeResource struct {
 	CredentialApiKey           string `json:"credential-api-key"`
 	HostLevelManagementApiKey  string `json:"host-level-management-api-key"`
 }
+
+func (ne *NuvlaEdgeResource) New() NuvlaResource {
+	return &NuvlaEdgeResource{}
+}
@@ -2,7 +2,7 @@ package resources
 
 import (
 	"encoding/json"
-	log "github.com/sirupsen/logrus"
+	"github.com/nuvla/api-client-go/common"
 	"io"
 	"net/http"
 )
@@ -11,6 +11,7 @@ type NuvlaResourceCollection struct {
 	Resources    []map[string]interface{} `json:"resources"`
 	Count        int                      `json:"count"`
 	ResourceName string                   `json:"id"`
+	Aggregations Aggregations             `json:"aggregations,omitempty"`
 }
 
 // NewCollectionFromResponse creates a NuvlaResourceCollection from a http.Response. It expects the body
@@ -22,14 +23,14 @@ func NewCollectionFromResponse(response *http.Response) (*NuvlaResourceCollectio
 	body, err := io.ReadAll(response.Body)
 	defer response.Body.Close()
 	if err != nil {
-		log.Errorf("Error reading response body: %s", err)
+		common.DefaultLogger().Errorf("Error reading response body: %s", err)
 		return nil, err
 	}
 
 	collection := &NuvlaResourceCollection{}
 	err = json.Unmarshal(body, collection)
 	if err != nil {
-		log.Errorf("Error unmarshaling response body: %s", err)
+		common.DefaultLogger().Errorf("Error unmarshaling response body: %s", err)
 		return nil, err
 	}
 
@@ -0,0 +1,43 @@
+package resources
+
+import (
+	"strings"
+	"time"
+)
+
+// SessionResource is the Nuvla session of the authenticated user
+type SessionResource struct {
+	CommonAttributesResource
+
+	Method   string `json:"method"`
+	Template struct {
+		Href string `json:"href"`
+	} `json:"template"`
+
+	User        string    `json:"user"`
+	Identifier  string    `json:"identifier"`
+	Roles       string    `json:"roles"`
+	Groups      string    `json:"groups,omitempty"`
+	ActiveClaim string    `json:"active-claim"`
+	ClientIp    string    `json:"client-ip,omitempty"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+func (s *SessionResource) New() NuvlaResource {
+	return &SessionResource{}
+}
+
+// GetRoles returns the roles of the session. Nuvla stores them as a space separated list.
+func (s *SessionResource) GetRoles() []string {
+	return strings.Fields(s.Roles)
+}
+
+// GetGroups returns the groups the session user belongs to
+func (s *SessionResource) GetGroups() []string {
+	return strings.Fields(s.Groups)
+}
+
+// IsExpired returns true if the session expiry is in the past
+func (s *SessionResource) IsExpired() bool {
+	return !s.Expiry.IsZero() && s.Expiry.Before(time.Now())
+}
@@ -0,0 +1,18 @@
+package resources
+
+// SessionTemplateResource describes a login method available on the Nuvla instance. Its id is the href to log in
+// with, e.g. through types.NewTemplateLogInParams.
+type SessionTemplateResource struct {
+	CommonAttributesResource
+
+	// Method is the authentication method, e.g. "password", "api-key", "github" or "mitreid-token"
+	Method string `json:"method"`
+	// Instance distinguishes the templates of the same method, e.g. several OIDC providers
+	Instance    string `json:"instance"`
+	Group       string `json:"group,omitempty"`
+	RedirectUrl string `json:"redirect-url,omitempty"`
+}
+
+func (s *SessionTemplateResource) New() NuvlaResource {
+	return &SessionTemplateResource{}
+}
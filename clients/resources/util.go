@@ -17,4 +17,6 @@ const (
 	NuvlaBoxType            NuvlaResourceType = "nuvlabox"
 	JobType                 NuvlaResourceType = "job"
 	DeploymentParameterType NuvlaResourceType = "deployment-parameter"
+	SessionType             NuvlaResourceType = "session"
+	SessionTemplateType     NuvlaResourceType = "session-template"
 )
@@ -8,7 +8,7 @@ import (
 )
 
 type UserClient struct {
-	api_client_go.NuvlaClient
+	*api_client_go.NuvlaClient
 	UserID    *types.NuvlaID
 	SessionID *types.NuvlaID
 }
@@ -20,12 +20,16 @@ func NewUserClient(endpoint string, insecure bool, debug bool) *UserClient {
 	sessionOpts.Endpoint = endpoint
 
 	return &UserClient{
-		NuvlaClient: *api_client_go.NewNuvlaClient(nil, sessionOpts),
+		NuvlaClient: api_client_go.NewNuvlaClient(nil, sessionOpts),
 	}
 }
 
 func (c *UserClient) AddNuvlaEdge(ctx context.Context, data map[string]interface{}) (*types.NuvlaID, error) {
-	return c.Add(ctx, "nuvlabox", data)
+	res, err := c.Add(ctx, "nuvlabox", data)
+	if err != nil {
+		return nil, err
+	}
+	return res.GetResourceId()
 }
 
 func (c *UserClient) GetNuvlaEdge(ctx context.Context, id string, fields []string) (*types.NuvlaResource, error) {
@@ -33,7 +37,11 @@ func (c *UserClient) GetNuvlaEdge(ctx context.Context, id string, fields []strin
 }
 
 func (c *UserClient) AddCredential(ctx context.Context, data map[string]interface{}) (*types.NuvlaID, error) {
-	return c.Add(ctx, "credential", data)
+	res, err := c.Add(ctx, "credential", data)
+	if err != nil {
+		return nil, err
+	}
+	return res.GetResourceId()
 }
 
 func (c *UserClient) GetId() string {
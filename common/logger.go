@@ -0,0 +1,112 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"sync/atomic"
+)
+
+// Logger is the logging interface of the library. *slog.Logger implements it, and so can adapters to other
+// logging libraries. Args are key-value pairs, as in log/slog.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+// NewNopLogger returns a Logger discarding everything
+func NewNopLogger() Logger {
+	return nopLogger{}
+}
+
+var defaultLogger atomic.Pointer[PrintfLogger]
+
+func init() {
+	defaultLogger.Store(NewPrintfLogger(nil))
+}
+
+// SetDefaultLogger sets the logger of the clients created without one and of the functions not bound to a client.
+// A nil logger discards everything, which is the default.
+func SetDefaultLogger(l Logger) {
+	defaultLogger.Store(NewPrintfLogger(l))
+}
+
+// DefaultLogger returns the logger set with SetDefaultLogger
+func DefaultLogger() *PrintfLogger {
+	return defaultLogger.Load()
+}
+
+// PrintfLogger formats messages with fmt.Sprintf and redacts the secrets they contain before passing them to the
+// underlying Logger
+type PrintfLogger struct {
+	logger Logger
+}
+
+// NewPrintfLogger wraps l, or a no-op logger if l is nil
+func NewPrintfLogger(l Logger) *PrintfLogger {
+	if IsNilValueInterface(l) {
+		l = NewNopLogger()
+	}
+	return &PrintfLogger{logger: l}
+}
+
+// Logger returns the underlying Logger
+func (l *PrintfLogger) Logger() Logger {
+	return l.logger
+}
+
+func (l *PrintfLogger) Debug(msg string) { l.logger.Debug(Redact(msg)) }
+func (l *PrintfLogger) Info(msg string)  { l.logger.Info(Redact(msg)) }
+func (l *PrintfLogger) Warn(msg string)  { l.logger.Warn(Redact(msg)) }
+func (l *PrintfLogger) Error(msg string) { l.logger.Error(Redact(msg)) }
+
+func (l *PrintfLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debug(Redact(fmt.Sprintf(format, args...)))
+}
+
+func (l *PrintfLogger) Infof(format string, args ...interface{}) {
+	l.logger.Info(Redact(fmt.Sprintf(format, args...)))
+}
+
+func (l *PrintfLogger) Warnf(format string, args ...interface{}) {
+	l.logger.Warn(Redact(fmt.Sprintf(format, args...)))
+}
+
+func (l *PrintfLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Error(Redact(fmt.Sprintf(format, args...)))
+}
+
+// Redacted replaces the secrets removed by Redact
+const Redacted = "[REDACTED]"
+
+var redactions = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// JSON: the whole api-credentials value, then the string values of secret keys
+	{regexp.MustCompile(`("api-credentials"\s*:\s*)(\{[^{}]*\}|"(?:[^"\\]|\\.)*")`), `${1}"` + Redacted + `"`},
+	{regexp.MustCompile(`(?i)("[\w-]*(?:secret|password|token|cookie)[\w-]*"\s*:\s*)"(?:[^"\\]|\\.)*"`), `${1}"` + Redacted + `"`},
+	// Go formatted maps and structs, e.g. map[api-credentials:map[...]] or {Key:credential/1 Secret:...}
+	{regexp.MustCompile(`(api-credentials:)map\[[^\]]*\]`), `${1}` + Redacted},
+	{regexp.MustCompile(`(?i)\b([\w-]*(?:secret|password|passwd|token)[\w-]*)(\s*[:=]\s*)[^\s,;&}\]"]+`), `${1}${2}` + Redacted},
+	// Cookie headers and the Nuvla session cookie
+	{regexp.MustCompile(`(?i)\b((?:set-)?cookie:\s*)[^\r\n]+`), `${1}` + Redacted},
+	{regexp.MustCompile(`(com\.sixsq\.nuvla\.cookie[=\s]+)[^;\s"}]+`), `${1}` + Redacted},
+}
+
+// Redact removes the API secrets, passwords, cookies and api-credentials from s, as found in log messages and
+// JSON documents
+func Redact(s string) string {
+	for _, r := range redactions {
+		s = r.pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
@@ -0,0 +1,65 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) Debug(msg string, _ ...interface{}) { l.lines = append(l.lines, msg) }
+func (l *recordingLogger) Info(msg string, _ ...interface{})  { l.lines = append(l.lines, msg) }
+func (l *recordingLogger) Warn(msg string, _ ...interface{})  { l.lines = append(l.lines, msg) }
+func (l *recordingLogger) Error(msg string, _ ...interface{}) { l.lines = append(l.lines, msg) }
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in     string
+		secret string
+	}{
+		{`{"template":{"href":"session-template/api-key","key":"credential/1","secret":"s3cr3t"}}`, "s3cr3t"},
+		{`{"username": "jane", "password": "p4ss\"word"}`, `p4ss\"word`},
+		{`{"api-key":"credential/1","secret-key":"s3cr3t"}`, "s3cr3t"},
+		{`{"api-credentials":{"api-key":"credential/1","api-secret":"s3cr3t"}}`, "credential/1"},
+		{fmt.Sprintf("%+v", struct{ Key, Secret string }{"credential/1", "s3cr3t"}), "s3cr3t"},
+		{fmt.Sprintf("%v", map[string]interface{}{"api-credentials": map[string]string{"api-key": "credential/1"}}), "credential/1"},
+		{"https://nuvla.io/api/callback?token=t0k3n&x=1", "t0k3n"},
+		{"Adding header Cookie: com.sixsq.nuvla.cookie=c00k13; other=1", "c00k13"},
+		{"&{com.sixsq.nuvla.cookie c00k13 / nuvla.io}", "c00k13"},
+	}
+	for _, tt := range tests {
+		got := Redact(tt.in)
+		if strings.Contains(got, tt.secret) || !strings.Contains(got, Redacted) {
+			t.Errorf("secret not redacted from %q: %q", tt.in, got)
+		}
+	}
+
+	clean := "Sending [PUT] request to endpoint: https://nuvla.io/api/nuvlabox/1"
+	if got := Redact(clean); got != clean {
+		t.Errorf("expected %q unchanged, got %q", clean, got)
+	}
+}
+
+func TestPrintfLogger_Redacts(t *testing.T) {
+	rec := &recordingLogger{}
+	l := NewPrintfLogger(rec)
+	l.Debugf("Login payload: %s", `{"key":"credential/1","secret":"s3cr3t"}`)
+	l.Errorf("Error logging in with %+v", struct{ Key, Secret string }{"credential/1", "s3cr3t"})
+
+	if len(rec.lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(rec.lines))
+	}
+	for _, line := range rec.lines {
+		if strings.Contains(line, "s3cr3t") {
+			t.Errorf("secret logged: %s", line)
+		}
+	}
+}
+
+func TestNewPrintfLogger_Nil(t *testing.T) {
+	// Must not panic
+	NewPrintfLogger(nil).Infof("nothing %d", 1)
+}
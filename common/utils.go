@@ -2,7 +2,6 @@ package common
 
 import (
 	"encoding/json"
-	log "github.com/sirupsen/logrus"
 	"io"
 	"net/http"
 	"os"
@@ -26,7 +25,7 @@ func FileExistsAndNotEmpty(filePath string) bool {
 
 func BuildDirectoryStructureIfNotExists(path string) error {
 	if FileExists(path) {
-		log.Infof("Directory %s already exists", path)
+		DefaultLogger().Debugf("Directory %s already exists", path)
 		return nil
 	}
 	return os.MkdirAll(path, os.ModePerm)
@@ -49,7 +48,8 @@ func WriteIndentedJSONToFile(data interface{}, path string) error {
 		return err
 	}
 
-	log.Infof("Writing Marshalled data: %s to file", jsonData)
+	// The data is not logged: it can hold credentials
+	DefaultLogger().Debugf("Writing %d bytes of JSON to %s", len(jsonData), path)
 
 	return WriteBytesToFile(jsonData, path)
 }
@@ -96,6 +96,8 @@ func GetCleanMapFromStruct(st interface{}) map[string]interface{} {
 		if !valueField.IsZero() {
 			if typeField.Name == "First" || typeField.Name == "Last" {
 				m[jsonTag] = strconv.Itoa(int(valueField.Int()))
+			} else if valueField.Kind() == reflect.Slice {
+				m[jsonTag] = valueField.Interface()
 			} else {
 				m[jsonTag] = valueField.String()
 			}
@@ -112,7 +114,7 @@ func CloseGenericResponseWithLog(resp *http.Response, respErr error) {
 
 	// Log if err is not nil
 	if respErr != nil {
-		log.Warnf("Error present together with response: %s", respErr)
+		DefaultLogger().Warnf("Error present together with response: %s", respErr)
 	}
 
 	method := ""
@@ -123,10 +125,10 @@ func CloseGenericResponseWithLog(resp *http.Response, respErr error) {
 		endpoint = resp.Request.URL.String()
 	}
 
-	log.Debugf("Closing response [%s]-%s", method, endpoint)
+	DefaultLogger().Debugf("Closing response [%s]-%s", method, endpoint)
 	err := resp.Body.Close()
 	if err != nil {
-		log.Warnf("Error closing responses %s body: %s", endpoint, err)
+		DefaultLogger().Warnf("Error closing responses %s body: %s", endpoint, err)
 	}
 }
 
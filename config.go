@@ -0,0 +1,215 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nuvla/api-client-go/common"
+	"github.com/nuvla/api-client-go/types"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Environment variables overriding the configuration profiles
+const (
+	EnvConfigFile = "NUVLA_CONFIG_FILE"
+	EnvProfile    = "NUVLA_PROFILE"
+	EnvEndpoint   = "NUVLA_ENDPOINT"
+	EnvInsecure   = "NUVLA_INSECURE"
+	EnvApiKey     = "NUVLA_API_KEY"
+	EnvApiSecret  = "NUVLA_API_SECRET"
+	EnvUsername   = "NUVLA_USERNAME"
+	EnvPassword   = "NUVLA_PASSWORD"
+	EnvCookieFile = "NUVLA_COOKIE_FILE"
+	EnvCompress   = "NUVLA_COMPRESS"
+)
+
+const DefaultProfileName = "default"
+
+// Profile is a named set of connection settings and credentials
+type Profile struct {
+	Endpoint   string `json:"endpoint,omitempty"`
+	Insecure   bool   `json:"insecure,omitempty"`
+	ApiKey     string `json:"api-key,omitempty"`
+	ApiSecret  string `json:"api-secret,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Password   string `json:"password,omitempty"`
+	CookieFile string `json:"cookie-file,omitempty"`
+	Compress   *bool  `json:"compress,omitempty"`
+}
+
+// ConfigFile is the content of the user configuration file, e.g.
+//
+//	{
+//	  "profiles": {
+//	    "default": {"endpoint": "https://nuvla.io", "api-key": "credential/...", "api-secret": "..."},
+//	    "on-prem": {"endpoint": "https://nuvla.local", "insecure": true, "username": "admin"}
+//	  }
+//	}
+type ConfigFile struct {
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// DefaultConfigFile returns the path of the configuration file: NUVLA_CONFIG_FILE if set, otherwise
+// <user config dir>/nuvla/config.json. It fails if the user configuration directory is unknown.
+func DefaultConfigFile() (string, error) {
+	if f := os.Getenv(EnvConfigFile); f != "" {
+		return f, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate the nuvla config file: %w", err)
+	}
+	return filepath.Join(dir, "nuvla", "config.json"), nil
+}
+
+// DefaultCookieFile returns the path of the cookie file used when SessionOptions.CookieFile is empty:
+// <user cache dir>/nuvla/cookies. It fails if the user cache directory is unknown.
+func DefaultCookieFile() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate the nuvla cookie file: %w", err)
+	}
+	return filepath.Join(dir, "nuvla", "cookies"), nil
+}
+
+// LoadConfigFile reads the configuration file
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	config := &ConfigFile{}
+	if err := common.ReadJSONFromFile(path, config); err != nil {
+		return nil, fmt.Errorf("error loading nuvla config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// LoadProfile reads the named profile from the configuration file and overlays the environment variables.
+// If path is empty, DefaultConfigFile is used. If name is empty, NUVLA_PROFILE is used, falling back to the
+// default profile. A missing configuration file is not an error, so the profile can be built from the
+// environment only.
+func LoadProfile(path, name string) (*Profile, error) {
+	if path == "" {
+		p, err := DefaultConfigFile()
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+	if name == "" {
+		name = os.Getenv(EnvProfile)
+	}
+	if name == "" {
+		name = DefaultProfileName
+	}
+
+	profile := &Profile{}
+	if common.FileExists(path) {
+		config, err := LoadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		p, ok := config.Profiles[name]
+		if !ok && name != DefaultProfileName {
+			return nil, fmt.Errorf("profile %s not found in %s", name, path)
+		}
+		*profile = p
+	} else {
+		common.DefaultLogger().Debugf("Nuvla config file %s not found, using environment only", path)
+	}
+
+	if err := profile.applyEnv(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+func (p *Profile) applyEnv() error {
+	overrideString := func(env string, field *string) {
+		if v, ok := os.LookupEnv(env); ok {
+			*field = v
+		}
+	}
+	overrideString(EnvEndpoint, &p.Endpoint)
+	overrideString(EnvApiKey, &p.ApiKey)
+	overrideString(EnvApiSecret, &p.ApiSecret)
+	overrideString(EnvUsername, &p.Username)
+	overrideString(EnvPassword, &p.Password)
+	overrideString(EnvCookieFile, &p.CookieFile)
+
+	if v, ok := os.LookupEnv(EnvInsecure); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", v, EnvInsecure, err)
+		}
+		p.Insecure = b
+	}
+	if v, ok := os.LookupEnv(EnvCompress); ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", v, EnvCompress, err)
+		}
+		p.Compress = &b
+	}
+	return nil
+}
+
+// LogInParams returns the credentials of the profile, preferring API keys over username and password.
+// It returns nil if the profile has no complete credentials.
+func (p *Profile) LogInParams() types.LogInParams {
+	if p.ApiKey != "" && p.ApiSecret != "" {
+		return types.NewApiKeyLogInParams(p.ApiKey, p.ApiSecret)
+	}
+	if p.Username != "" && p.Password != "" {
+		return types.NewUserLogInParams(p.Username, p.Password)
+	}
+	return nil
+}
+
+// SessionOptFunc returns the session options defined by the profile
+func (p *Profile) SessionOptFunc() SessionOptFunc {
+	return func(opts *SessionOptions) {
+		if p.Endpoint != "" {
+			opts.Endpoint = p.Endpoint
+		}
+		if p.Insecure {
+			opts.Insecure = true
+		}
+		if p.CookieFile != "" {
+			opts.PersistCookie = true
+			opts.CookieFile = p.CookieFile
+		}
+		if p.Compress != nil {
+			opts.Compress = *p.Compress
+		}
+	}
+}
+
+// NewNuvlaClientFromProfile creates a client configured from the named profile of the default configuration file
+// and the environment variables, and logs in if the profile has credentials. The profile is also used as
+// CredentialProvider, so credentials rotated in the file or the environment are used on re-authentication.
+// Additional options are applied after the profile. It returns an error if the login fails.
+func NewNuvlaClientFromProfile(ctx context.Context, name string, opts ...SessionOptFunc) (*NuvlaClient, error) {
+	profile, err := LoadProfile("", name)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &ProfileCredentialProvider{Profile: name}
+	sessionOpts := DefaultSessionOpts()
+	for _, fn := range append([]SessionOptFunc{profile.SessionOptFunc(), WithCredentialProvider(provider)}, opts...) {
+		fn(sessionOpts)
+	}
+	nc := newNuvlaClient(sessionOpts)
+
+	cred, fromProvider, err := nc.resolveCredentials(ctx, nil)
+	if errors.Is(err, ErrNoCredentials) {
+		return nc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := nc.loginWith(ctx, cred, fromProvider); err != nil {
+		return nil, err
+	}
+	return nc, nil
+}
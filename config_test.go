@@ -0,0 +1,202 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/nuvla/api-client-go/common"
+	"github.com/nuvla/api-client-go/types"
+)
+
+// unsetNuvlaEnv removes the NUVLA_* variables for the duration of the test
+func unsetNuvlaEnv(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{EnvConfigFile, EnvProfile, EnvEndpoint, EnvInsecure, EnvApiKey, EnvApiSecret,
+		EnvUsername, EnvPassword, EnvCookieFile, EnvCompress} {
+		t.Setenv(env, "")
+		_ = os.Unsetenv(env)
+	}
+}
+
+// writeTestConfigFile writes a configuration file with a default and an on-prem profile
+func writeTestConfigFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := &ConfigFile{Profiles: map[string]Profile{
+		DefaultProfileName: {Endpoint: "https://nuvla.io", ApiKey: "credential/file", ApiSecret: "file-secret"},
+		"on-prem":          {Endpoint: "https://nuvla.local", Insecure: true, Username: "admin", Password: "file-password"},
+	}}
+	if err := common.WriteIndentedJSONToFile(config, path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultCookieFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("relies on the XDG cache directory")
+	}
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	path, err := DefaultCookieFile()
+	if err != nil || path != filepath.Join(cache, "nuvla", "cookies") {
+		t.Errorf("expected the cookie file in %s, got %s, error %v", cache, path, err)
+	}
+
+	// Never fall back to a shared directory
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("HOME", "")
+	if path, err := DefaultCookieFile(); err == nil {
+		t.Errorf("expected an error without home directory, got %s", path)
+	}
+}
+
+func TestDefaultConfigFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("relies on the XDG config directory")
+	}
+	t.Setenv(EnvConfigFile, "")
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	path, err := DefaultConfigFile()
+	if err != nil || path != filepath.Join(config, "nuvla", "config.json") {
+		t.Errorf("expected the config file in %s, got %s, error %v", config, path, err)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "")
+	if path, err := DefaultConfigFile(); err == nil {
+		t.Errorf("expected an error without home directory, got %s", path)
+	}
+	if _, err := LoadProfile("", ""); err == nil {
+		t.Error("expected LoadProfile to fail without config file location")
+	}
+}
+
+func TestNewNuvlaSession_DefaultCookieFile(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("relies on the XDG cache directory")
+	}
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+
+	s := NewNuvlaSession(DefaultSessionOpts())
+	if got := s.GetSessionOpts().CookieFile; got != filepath.Join(cache, "nuvla", "cookies") {
+		t.Errorf("expected the default cookie file in %s, got %s", cache, got)
+	}
+
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("HOME", "")
+	s = NewNuvlaSession(DefaultSessionOpts())
+	if _, ok := s.cookies.store.(*MemoryCookieStore); !ok {
+		t.Errorf("expected cookies in memory without cache directory, got %T", s.cookies.store)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		env     map[string]string
+		want    Profile
+		wantErr bool
+	}{
+		{
+			name: "default profile from file",
+			want: Profile{Endpoint: "https://nuvla.io", ApiKey: "credential/file", ApiSecret: "file-secret"},
+		},
+		{
+			name:    "named profile from file",
+			profile: "on-prem",
+			want:    Profile{Endpoint: "https://nuvla.local", Insecure: true, Username: "admin", Password: "file-password"},
+		},
+		{
+			name: "profile selected by the environment",
+			env:  map[string]string{EnvProfile: "on-prem"},
+			want: Profile{Endpoint: "https://nuvla.local", Insecure: true, Username: "admin", Password: "file-password"},
+		},
+		{
+			name:    "environment overrides the file",
+			profile: "on-prem",
+			env: map[string]string{EnvEndpoint: "https://nuvla.env", EnvInsecure: "false", EnvPassword: "env-password",
+				EnvCookieFile: "/run/nuvla/cookies"},
+			want: Profile{Endpoint: "https://nuvla.env", Username: "admin", Password: "env-password",
+				CookieFile: "/run/nuvla/cookies"},
+		},
+		{
+			name: "variable set to empty clears the file value",
+			env:  map[string]string{EnvApiSecret: ""},
+			want: Profile{Endpoint: "https://nuvla.io", ApiKey: "credential/file"},
+		},
+		{
+			name:    "unknown profile",
+			profile: "missing",
+			wantErr: true,
+		},
+		{
+			name:    "invalid boolean",
+			env:     map[string]string{EnvInsecure: "maybe"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetNuvlaEnv(t)
+			path := writeTestConfigFile(t)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			profile, err := LoadProfile(path, tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error: %t, got %v", tt.wantErr, err)
+			}
+			if err == nil && *profile != tt.want {
+				t.Errorf("got %+v, want %+v", *profile, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadProfile_EnvironmentOnly(t *testing.T) {
+	unsetNuvlaEnv(t)
+	t.Setenv(EnvEndpoint, "https://nuvla.env")
+	t.Setenv(EnvApiKey, "credential/env")
+	t.Setenv(EnvApiSecret, "env-secret")
+
+	profile, err := LoadProfile(filepath.Join(t.TempDir(), "missing.json"), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if profile.Endpoint != "https://nuvla.env" {
+		t.Errorf("expected the endpoint of the environment, got %s", profile.Endpoint)
+	}
+	if params, ok := profile.LogInParams().(*types.ApiKeyLogInParams); !ok || params.Key != "credential/env" {
+		t.Errorf("expected the api key of the environment, got %v", profile.LogInParams())
+	}
+}
+
+func TestNewNuvlaClientFromProfile(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	unsetNuvlaEnv(t)
+	t.Setenv(EnvConfigFile, writeTestConfigFile(t))
+	t.Setenv(EnvEndpoint, s.URL)
+
+	c, err := NewNuvlaClientFromProfile(context.Background(), "", WithoutPersistCookie)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := s.logins.Load(); n != 1 || c.CurrentCredentials() == nil {
+		t.Errorf("expected a login with the profile credentials, got %d logins", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewNuvlaClientFromProfile(ctx, "", WithoutPersistCookie); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the login to be cancelled, got %v", err)
+	}
+}
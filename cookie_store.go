@@ -0,0 +1,542 @@
+package api_client_go
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/nuvla/api-client-go/common"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CookieStore persists the session cookies of Nuvla endpoints. Cookies are kept per endpoint host and port, so a
+// single store can hold the sessions of several endpoints side by side.
+type CookieStore interface {
+	// Load returns the cookies stored for the endpoint. It returns no cookies and no error if there are none.
+	Load(endpoint *url.URL) ([]*http.Cookie, error)
+	// Save replaces the cookies stored for the endpoint
+	Save(endpoint *url.URL, cookies []*http.Cookie) error
+	// Clear removes the cookies stored for the endpoint
+	Clear(endpoint *url.URL) error
+}
+
+// cookieStoreKey returns the key under which the cookies of the endpoint are stored: its lower-case host and
+// port, the default port of the scheme if none is given, so equivalent endpoint URLs share their cookies
+func cookieStoreKey(endpoint *url.URL) string {
+	return hostPort(endpoint)
+}
+
+/****************************************************************************************
+************************ In-memory store **********************************************
+****************************************************************************************/
+
+// MemoryCookieStore keeps the cookies in memory only. Sessions are lost when the process exits.
+type MemoryCookieStore struct {
+	mu      sync.Mutex
+	cookies map[string][]*http.Cookie
+}
+
+func NewMemoryCookieStore() *MemoryCookieStore {
+	return &MemoryCookieStore{
+		cookies: make(map[string][]*http.Cookie),
+	}
+}
+
+func (s *MemoryCookieStore) Load(endpoint *url.URL) ([]*http.Cookie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyCookies(s.cookies[cookieStoreKey(endpoint)]), nil
+}
+
+func (s *MemoryCookieStore) Save(endpoint *url.URL, cookies []*http.Cookie) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cookies[cookieStoreKey(endpoint)] = copyCookies(cookies)
+	return nil
+}
+
+func (s *MemoryCookieStore) Clear(endpoint *url.URL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cookies, cookieStoreKey(endpoint))
+	return nil
+}
+
+func copyCookies(cookies []*http.Cookie) []*http.Cookie {
+	if cookies == nil {
+		return nil
+	}
+	c := make([]*http.Cookie, 0, len(cookies))
+	for _, cookie := range cookies {
+		cp := *cookie
+		c = append(c, &cp)
+	}
+	return c
+}
+
+/****************************************************************************************
+************************ File stores **********************************************
+****************************************************************************************/
+
+// cookieTombstoneTTL is how long the deletion of a cookie is remembered in cookie files
+const cookieTombstoneTTL = 24 * time.Hour
+
+// storedCookie is a cookie as stored in cookie files, with the time it was last written. Deleted cookies are kept
+// as tombstones for cookieTombstoneTTL, so the deletion wins over older copies of the cookie.
+type storedCookie struct {
+	*http.Cookie
+	Written time.Time `json:"written"`
+	Deleted bool      `json:"deleted,omitempty"`
+}
+
+// cookieFileContent is the document stored in cookie files: the cookies indexed by cookieStoreKey
+type cookieFileContent map[string][]*storedCookie
+
+// FileCookieStore stores the cookies in a file readable only by the owner (mode 0600). Files are replaced
+// atomically, so a crash never leaves a truncated file behind. The content is optionally encrypted.
+//
+// The file can be shared by several processes: reads and writes are protected by an advisory lock on
+// "<path>.lock", and HasChanged reports whether another process modified the file since it was last read or
+// written. Saving only writes the cookies which changed since the store last read or wrote the file, and for
+// every cookie name the latest write wins, deletions included. The processes sharing a file therefore share the
+// session, and the identity, of the last one which logged in: use separate files for separate identities.
+type FileCookieStore struct {
+	path string
+	aead cipher.AEAD
+	mu   sync.Mutex
+	// log is the logger of the session using the store, the default logger if nil
+	log *common.PrintfLogger
+
+	// lastInfo is the state of the file after the last read or write of this store
+	lastInfo os.FileInfo
+	// known holds, per cookieStoreKey, the cookies of the file as last read or written by this store
+	known map[string]map[string]*storedCookie
+}
+
+// NewFileCookieStore creates a store saving the cookies as plain JSON in path
+func NewFileCookieStore(path string) *FileCookieStore {
+	return &FileCookieStore{
+		path:  path,
+		known: make(map[string]map[string]*storedCookie),
+	}
+}
+
+// NewEncryptedFileCookieStore creates a store saving the cookies in path encrypted with AES-GCM.
+// The key must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+func NewEncryptedFileCookieStore(path string, key []byte) (*FileCookieStore, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cookie encryption key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FileCookieStore{
+		path:  path,
+		aead:  aead,
+		known: make(map[string]map[string]*storedCookie),
+	}, nil
+}
+
+// Path returns the path of the cookie file
+func (s *FileCookieStore) Path() string {
+	return s.path
+}
+
+// setLogger makes the store log to logger, unless it already has a logger
+func (s *FileCookieStore) setLogger(logger *common.PrintfLogger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		s.log = logger
+	}
+}
+
+func (s *FileCookieStore) logger() *common.PrintfLogger {
+	if s.log == nil {
+		return common.DefaultLogger()
+	}
+	return s.log
+}
+
+func (s *FileCookieStore) Load(endpoint *url.URL) ([]*http.Cookie, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	content, err := s.read(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	key := cookieStoreKey(endpoint)
+	s.remember(key, content[key])
+	return liveCookies(content[key]), nil
+}
+
+// Save replaces the cookies stored for the endpoint. Only the cookies added, modified or removed since the store
+// last read or wrote the file are written: the other ones, possibly written by other processes since, are kept.
+func (s *FileCookieStore) Save(endpoint *url.URL, cookies []*http.Cookie) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := s.read(endpoint)
+	if err != nil {
+		s.logger().Warnf("Cannot read cookie file %s, it will be overwritten: %s", s.path, err)
+		content = make(cookieFileContent)
+	}
+	key := cookieStoreKey(endpoint)
+	content[key] = mergeCookies(content[key], s.changes(key, cookies, time.Now()))
+	if err := s.write(content); err != nil {
+		return err
+	}
+	s.remember(key, content[key])
+	return nil
+}
+
+func (s *FileCookieStore) Clear(endpoint *url.URL) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := s.read(endpoint)
+	if err != nil {
+		s.logger().Warnf("Cannot read cookie file %s, it will be overwritten: %s", s.path, err)
+		content = make(cookieFileContent)
+	}
+	key := cookieStoreKey(endpoint)
+
+	// Remember the deletion of every cookie, so older copies saved by other processes do not come back
+	now := time.Now()
+	var tombstones []*storedCookie
+	for _, c := range content[key] {
+		tombstones = append(tombstones, newCookieTombstone(c.Name, now))
+	}
+	for name := range s.known[key] {
+		tombstones = append(tombstones, newCookieTombstone(name, now))
+	}
+	if len(tombstones) == 0 {
+		return nil
+	}
+	content[key] = mergeCookies(content[key], tombstones)
+	if err := s.write(content); err != nil {
+		return err
+	}
+	s.remember(key, content[key])
+	return nil
+}
+
+// HasChanged returns true if the file was modified, replaced or removed since this store last read or wrote it
+func (s *FileCookieStore) HasChanged() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return s.lastInfo != nil
+	}
+	if s.lastInfo == nil {
+		return true
+	}
+	return !os.SameFile(s.lastInfo, info) ||
+		!info.ModTime().Equal(s.lastInfo.ModTime()) ||
+		info.Size() != s.lastInfo.Size()
+}
+
+// lock acquires the advisory lock shared by all the processes using the cookie file. A separate lock file is
+// used because the cookie file itself is replaced on every write.
+func (s *FileCookieStore) lock(exclusive bool) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(s.path+".lock", lockFileFlags, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("error locking cookie file %s: %w", s.path, err)
+	}
+	return func() {
+		if err := unlockFile(f); err != nil {
+			s.logger().Warnf("Error unlocking cookie file %s: %s", s.path, err)
+		}
+		_ = f.Close()
+	}, nil
+}
+
+// changes returns the cookies of the endpoint key written at now: the ones which differ from the cookies last read
+// or written by this store, and tombstones for the ones which were removed. Unchanged cookies keep their previous
+// write time, so they do not override the copies written by other processes since.
+func (s *FileCookieStore) changes(key string, cookies []*http.Cookie, now time.Time) []*storedCookie {
+	known := s.known[key]
+	changes := make([]*storedCookie, 0, len(cookies))
+	saved := make(map[string]bool, len(cookies))
+	for _, c := range cookies {
+		saved[c.Name] = true
+		if k, ok := known[c.Name]; ok && !k.Deleted && sameCookie(k.Cookie, c) {
+			changes = append(changes, k)
+			continue
+		}
+		cp := *c
+		changes = append(changes, &storedCookie{Cookie: &cp, Written: now})
+	}
+	for name, k := range known {
+		if !saved[name] && !k.Deleted {
+			changes = append(changes, newCookieTombstone(name, now))
+		}
+	}
+	return changes
+}
+
+// remember records the cookies of the endpoint key as read or written by this store
+func (s *FileCookieStore) remember(key string, cookies []*storedCookie) {
+	known := make(map[string]*storedCookie, len(cookies))
+	for _, c := range cookies {
+		known[c.Name] = c
+	}
+	s.known[key] = known
+}
+
+func newCookieTombstone(name string, now time.Time) *storedCookie {
+	return &storedCookie{Cookie: &http.Cookie{Name: name}, Written: now, Deleted: true}
+}
+
+// sameCookie tells whether a and b have the same value and expiry
+func sameCookie(a, b *http.Cookie) bool {
+	return a.Value == b.Value && a.Expires.Equal(b.Expires) && a.MaxAge == b.MaxAge
+}
+
+// mergeCookies combines the stored cookies with the new ones. For every cookie name, the one written last wins,
+// the new one in case of a tie. Expired cookies and old tombstones are dropped.
+func mergeCookies(stored, cookies []*storedCookie) []*storedCookie {
+	merged := make([]*storedCookie, 0, len(stored)+len(cookies))
+	byName := make(map[string]int)
+	for _, c := range cookies {
+		if i, ok := byName[c.Name]; ok {
+			if !merged[i].Written.After(c.Written) {
+				merged[i] = c
+			}
+			continue
+		}
+		byName[c.Name] = len(merged)
+		merged = append(merged, c)
+	}
+	for _, c := range stored {
+		i, ok := byName[c.Name]
+		if !ok {
+			byName[c.Name] = len(merged)
+			merged = append(merged, c)
+			continue
+		}
+		if c.Written.After(merged[i].Written) {
+			merged[i] = c
+		}
+	}
+
+	now := time.Now()
+	kept := merged[:0]
+	for _, c := range merged {
+		if c.Deleted && now.Sub(c.Written) > cookieTombstoneTTL {
+			continue
+		}
+		if !c.Deleted && !c.Expires.IsZero() && c.Expires.Before(now) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
+
+// liveCookies returns the cookies which are neither deleted nor expired
+func liveCookies(stored []*storedCookie) []*http.Cookie {
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, c := range stored {
+		if c.Deleted || (!c.Expires.IsZero() && c.Expires.Before(now)) {
+			continue
+		}
+		cp := *c.Cookie
+		cookies = append(cookies, &cp)
+	}
+	return cookies
+}
+
+// read loads the whole cookie file. A missing or empty file results in empty content.
+func (s *FileCookieStore) read(endpoint *url.URL) (cookieFileContent, error) {
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		s.lastInfo = nil
+		return make(cookieFileContent), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	s.lastInfo = info
+	if len(b) == 0 {
+		return make(cookieFileContent), nil
+	}
+
+	if s.aead != nil {
+		if b, err = s.decrypt(b); err != nil {
+			return nil, err
+		}
+	}
+	return decodeCookieFile(b, endpoint)
+}
+
+// write replaces the cookie file atomically: the content is written to a temporary file in the same directory,
+// which is then renamed over the cookie file.
+func (s *FileCookieStore) write(content cookieFileContent) error {
+	b, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	if s.aead != nil {
+		if b, err = s.encrypt(b); err != nil {
+			return err
+		}
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() {
+		// No-op once renamed
+		_ = os.Remove(tmpName)
+	}()
+
+	// CreateTemp already uses 0600, make sure it is kept regardless of the umask
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, s.path); err != nil {
+		return err
+	}
+
+	// Still under the lock, so the file is the one just written
+	if info, err := os.Stat(s.path); err == nil {
+		s.lastInfo = info
+	}
+	return nil
+}
+
+func (s *FileCookieStore) encrypt(plain []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, plain, nil), nil
+}
+
+func (s *FileCookieStore) decrypt(data []byte) ([]byte, error) {
+	n := s.aead.NonceSize()
+	if len(data) < n {
+		return nil, errors.New("encrypted cookie file is too short")
+	}
+	plain, err := s.aead.Open(nil, data[:n], data[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting cookie file: %w", err)
+	}
+	return plain, nil
+}
+
+// decodeCookieFile decodes the cookie file content. Files written by previous versions of the library contain one
+// JSON cookie per line for a single endpoint, or index the cookies by endpoint host: these cookies are assigned to
+// the endpoint being loaded. Cookies without write time are considered older than any other.
+func decodeCookieFile(b []byte, endpoint *url.URL) (cookieFileContent, error) {
+	content := make(cookieFileContent)
+	if err := json.Unmarshal(b, &content); err == nil {
+		for key, cookies := range content {
+			content[key] = validStoredCookies(cookies)
+		}
+		// Files written before the keys were normalised index the cookies by the endpoint host as given
+		if key := cookieStoreKey(endpoint); endpoint.Host != key {
+			if legacy, ok := content[endpoint.Host]; ok {
+				content[key] = mergeCookies(legacy, content[key])
+				delete(content, endpoint.Host)
+			}
+		}
+		return content, nil
+	}
+	content = make(cookieFileContent)
+
+	var cookies []*storedCookie
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var cookie http.Cookie
+		if err := json.Unmarshal(line, &cookie); err != nil {
+			return nil, fmt.Errorf("unknown cookie file format: %w", err)
+		}
+		cookies = append(cookies, &storedCookie{Cookie: &cookie})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	content[cookieStoreKey(endpoint)] = cookies
+	return content, nil
+}
+
+// validStoredCookies drops the null entries of a decoded file, which have no cookie
+func validStoredCookies(cookies []*storedCookie) []*storedCookie {
+	valid := cookies[:0]
+	for _, c := range cookies {
+		if c != nil && c.Cookie != nil {
+			valid = append(valid, c)
+		}
+	}
+	return valid
+}
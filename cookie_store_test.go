@@ -0,0 +1,520 @@
+package api_client_go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testCookieEndpoint, _ = url.Parse("https://nuvla.test")
+
+func newSessionCookie(value string, expires time.Duration) *http.Cookie {
+	return &http.Cookie{Name: testSessionCookie, Value: value, Path: "/", Expires: time.Now().Add(expires).Truncate(time.Second)}
+}
+
+// cookieValues returns the values of cookies by name
+func cookieValues(cookies []*http.Cookie) map[string]string {
+	values := make(map[string]string, len(cookies))
+	for _, c := range cookies {
+		values[c.Name] = c.Value
+	}
+	return values
+}
+
+func mustLoadCookies(t *testing.T, s CookieStore) map[string]string {
+	t.Helper()
+	cookies, err := s.Load(testCookieEndpoint)
+	if err != nil {
+		t.Fatalf("error loading cookies: %s", err)
+	}
+	return cookieValues(cookies)
+}
+
+func mustSaveCookies(t *testing.T, s CookieStore, cookies ...*http.Cookie) {
+	t.Helper()
+	if err := s.Save(testCookieEndpoint, cookies); err != nil {
+		t.Fatalf("error saving cookies: %s", err)
+	}
+}
+
+func TestFileCookieStore_SharedFileConcurrentSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies")
+	stores := []*FileCookieStore{NewFileCookieStore(path), NewFileCookieStore(path)}
+
+	const saves = 20
+	var wg sync.WaitGroup
+	for i, s := range stores {
+		wg.Add(1)
+		go func(i int, s *FileCookieStore) {
+			defer wg.Done()
+			name := fmt.Sprintf("cookie-%d", i)
+			for n := 0; n < saves; n++ {
+				// Save the whole jar, as NuvlaCookies does, with the other cookies as last loaded
+				cookies, err := s.Load(testCookieEndpoint)
+				if err != nil {
+					t.Errorf("error loading cookies: %s", err)
+				}
+				jar := []*http.Cookie{{Name: name, Value: fmt.Sprint(n), Path: "/"}}
+				for _, c := range cookies {
+					if c.Name != name {
+						jar = append(jar, c)
+					}
+				}
+				if err := s.Save(testCookieEndpoint, jar); err != nil {
+					t.Errorf("error saving cookies: %s", err)
+				}
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	got := mustLoadCookies(t, NewFileCookieStore(path))
+	want := map[string]string{"cookie-0": fmt.Sprint(saves - 1), "cookie-1": fmt.Sprint(saves - 1)}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFileCookieStore_SharedFileReLogin(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies")
+	a, b := NewFileCookieStore(path), NewFileCookieStore(path)
+
+	mustSaveCookies(t, a, newSessionCookie("first", 2*time.Hour))
+	stale := mustLoadCookies(t, b)
+	if stale[testSessionCookie] != "first" {
+		t.Fatalf("expected the first session, got %v", stale)
+	}
+
+	// a logs in again and gets a session expiring before the first one
+	mustSaveCookies(t, a, newSessionCookie("second", time.Hour))
+	// b saves another cookie while still holding the first session
+	mustSaveCookies(t, b, newSessionCookie("first", 2*time.Hour), &http.Cookie{Name: "other", Value: "b", Path: "/"})
+
+	got := mustLoadCookies(t, NewFileCookieStore(path))
+	if got[testSessionCookie] != "second" || got["other"] != "b" {
+		t.Errorf("expected the second session and the cookie of b, got %v", got)
+	}
+
+	// b logs in again in turn: its session is now the latest
+	mustLoadCookies(t, b)
+	mustSaveCookies(t, b, newSessionCookie("third", time.Hour), &http.Cookie{Name: "other", Value: "b", Path: "/"})
+	if got := mustLoadCookies(t, a); got[testSessionCookie] != "third" {
+		t.Errorf("expected the third session, got %v", got)
+	}
+}
+
+func TestFileCookieStore_SharedFileLogout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies")
+	a, b := NewFileCookieStore(path), NewFileCookieStore(path)
+
+	session := newSessionCookie("session", time.Hour)
+	mustSaveCookies(t, a, session)
+	mustLoadCookies(t, b)
+
+	if err := a.Clear(testCookieEndpoint); err != nil {
+		t.Fatalf("error clearing cookies: %s", err)
+	}
+	// b still holds the session when saving another cookie: the logout must win
+	mustSaveCookies(t, b, session, &http.Cookie{Name: "other", Value: "b", Path: "/"})
+
+	got := mustLoadCookies(t, NewFileCookieStore(path))
+	if _, ok := got[testSessionCookie]; ok || got["other"] != "b" {
+		t.Errorf("expected only the cookie of b after logout, got %v", got)
+	}
+
+	// A cookie removed by one store is deleted for the other ones
+	mustLoadCookies(t, a)
+	mustSaveCookies(t, a)
+	if got := mustLoadCookies(t, b); len(got) != 0 {
+		t.Errorf("expected no cookies, got %v", got)
+	}
+}
+
+func TestMergeCookies(t *testing.T) {
+	now := time.Now()
+	cookie := func(name, value string, written time.Duration, deleted bool) *storedCookie {
+		return &storedCookie{Cookie: &http.Cookie{Name: name, Value: value}, Written: now.Add(written), Deleted: deleted}
+	}
+	stored := []*storedCookie{
+		cookie("newer-stored", "stored", 0, false),
+		cookie("newer-saved", "stored", -time.Minute, false),
+		cookie("deleted", "stored", -time.Minute, false),
+		cookie("tie", "stored", 0, false),
+		cookie("old-tombstone", "", -2*cookieTombstoneTTL, true),
+		{Cookie: &http.Cookie{Name: "expired", Value: "stored", Expires: now.Add(-time.Minute)}, Written: now},
+		cookie("untouched", "stored", -time.Hour, false),
+	}
+	cookies := []*storedCookie{
+		cookie("newer-stored", "saved", -time.Minute, false),
+		cookie("newer-saved", "saved", 0, false),
+		cookie("deleted", "", 0, true),
+		cookie("tie", "saved", 0, false),
+	}
+
+	got := make(map[string]string)
+	for _, c := range mergeCookies(stored, cookies) {
+		got[c.Name] = c.Value
+		if c.Deleted {
+			got[c.Name] = "deleted"
+		}
+	}
+	want := map[string]string{
+		"newer-stored": "stored",
+		"newer-saved":  "saved",
+		"deleted":      "deleted",
+		"tie":          "saved",
+		"untouched":    "stored",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestFileCookieStore_LockFileSymlink(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no symbolic links")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target")
+	path := filepath.Join(dir, "cookies")
+	if err := os.Symlink(target, path+".lock"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewFileCookieStore(path).Save(testCookieEndpoint, []*http.Cookie{newSessionCookie("session", time.Hour)}); err == nil {
+		t.Error("expected an error with a symbolic link as lock file")
+	}
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		t.Errorf("expected the symbolic link target not to be created, got %v", err)
+	}
+}
+
+func TestEncryptedFileCookieStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies")
+	key := bytes.Repeat([]byte{1}, 32)
+	s, err := NewEncryptedFileCookieStore(path, key)
+	if err != nil {
+		t.Fatalf("error creating store: %s", err)
+	}
+	mustSaveCookies(t, s, newSessionCookie("s3cr3t-session", time.Hour))
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("s3cr3t-session")) || bytes.Contains(b, []byte(testSessionCookie)) {
+		t.Error("cookie file is not encrypted")
+	}
+
+	same, _ := NewEncryptedFileCookieStore(path, key)
+	if got := mustLoadCookies(t, same); got[testSessionCookie] != "s3cr3t-session" {
+		t.Errorf("expected the session with the same key, got %v", got)
+	}
+
+	wrong, _ := NewEncryptedFileCookieStore(path, bytes.Repeat([]byte{2}, 32))
+	if _, err := wrong.Load(testCookieEndpoint); err == nil {
+		t.Error("expected an error with the wrong key")
+	}
+	if _, err := NewFileCookieStore(path).Load(testCookieEndpoint); err == nil {
+		t.Error("expected an error reading an encrypted file without key")
+	}
+
+	if _, err := NewEncryptedFileCookieStore(path, []byte("short")); err == nil {
+		t.Error("expected an error with an invalid key size")
+	}
+}
+
+func TestFileCookieStore_PermissionsAndAtomicReplace(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no unix permissions")
+	}
+	dir := filepath.Join(t.TempDir(), "nuvla")
+	path := filepath.Join(dir, "cookies")
+	s := NewFileCookieStore(path)
+	mustSaveCookies(t, s, newSessionCookie("first", time.Hour))
+
+	for _, p := range []string{dir, path} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := os.FileMode(0600)
+		if info.IsDir() {
+			want = 0700
+		}
+		if perm := info.Mode().Perm(); perm != want {
+			t.Errorf("expected %s to have mode %o, got %o", p, want, perm)
+		}
+	}
+
+	// A reader holding the previous file keeps reading its complete content
+	previous, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer previous.Close()
+	before, _ := os.Stat(path)
+
+	// A file with loose permissions is replaced by a private one
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mustSaveCookies(t, s, newSessionCookie("second", time.Hour))
+
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if os.SameFile(before, after) {
+		t.Error("expected the cookie file to be replaced, not rewritten in place")
+	}
+	if perm := after.Mode().Perm(); perm != 0600 {
+		t.Errorf("expected mode 600 after replace, got %o", perm)
+	}
+	if b, err := io.ReadAll(previous); err != nil || !bytes.Contains(b, []byte("first")) {
+		t.Errorf("expected the previous content to stay intact, got %q, error %v", b, err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "cookies" && e.Name() != "cookies.lock" {
+			t.Errorf("unexpected file left behind: %s", e.Name())
+		}
+	}
+}
+
+func TestFileCookieStore_LegacyFormats(t *testing.T) {
+	session := newSessionCookie("legacy", time.Hour)
+	line, _ := json.Marshal(session)
+	other, _ := json.Marshal(&http.Cookie{Name: "other", Value: "legacy", Path: "/"})
+	byHost, _ := json.Marshal(map[string][]*http.Cookie{testCookieEndpoint.Host: {session}, "other.test": {session}})
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    map[string]string
+	}{
+		{"json lines", []byte(string(line) + "\n" + string(other) + "\n"), map[string]string{testSessionCookie: "legacy", "other": "legacy"}},
+		{"single json line", line, map[string]string{testSessionCookie: "legacy"}},
+		{"cookies by host without write time", byHost, map[string]string{testSessionCookie: "legacy"}},
+		{"empty file", nil, map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cookies")
+			if err := os.WriteFile(path, tt.content, 0600); err != nil {
+				t.Fatal(err)
+			}
+			s := NewFileCookieStore(path)
+			if got := mustLoadCookies(t, s); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+
+			// Any write is newer than the legacy cookies
+			mustSaveCookies(t, NewFileCookieStore(path), newSessionCookie("new", time.Hour))
+			if got := mustLoadCookies(t, s); got[testSessionCookie] != "new" {
+				t.Errorf("expected the new session, got %v", got)
+			}
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "cookies")
+	if err := os.WriteFile(path, []byte("not a cookie file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileCookieStore(path).Load(testCookieEndpoint); err == nil {
+		t.Error("expected an error with an unknown format")
+	}
+}
+
+func TestFileCookieStore_Lock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no advisory locks")
+	}
+	path := filepath.Join(t.TempDir(), "cookies")
+	holder := NewFileCookieStore(path)
+	unlock, err := holder.lock(true)
+	if err != nil {
+		t.Fatalf("error locking: %s", err)
+	}
+
+	saved := make(chan error, 1)
+	go func() {
+		saved <- NewFileCookieStore(path).Save(testCookieEndpoint, []*http.Cookie{newSessionCookie("session", time.Hour)})
+	}()
+	select {
+	case err := <-saved:
+		t.Fatalf("save did not wait for the lock: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	select {
+	case err := <-saved:
+		if err != nil {
+			t.Errorf("error saving after unlock: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("save still blocked after unlock")
+	}
+}
+
+func TestFileCookieStore_ClearAndHasChanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies")
+	a, b := NewFileCookieStore(path), NewFileCookieStore(path)
+	other, _ := url.Parse("https://other.test")
+
+	mustSaveCookies(t, a, newSessionCookie("session", time.Hour))
+	if err := a.Save(other, []*http.Cookie{newSessionCookie("other", time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if a.HasChanged() {
+		t.Error("expected no change after own write")
+	}
+	if !b.HasChanged() {
+		t.Error("expected a change for a store which never read the file")
+	}
+	mustLoadCookies(t, b)
+	if b.HasChanged() {
+		t.Error("expected no change after reading")
+	}
+
+	if err := a.Clear(testCookieEndpoint); err != nil {
+		t.Fatal(err)
+	}
+	if !b.HasChanged() {
+		t.Error("expected a change after another store cleared the cookies")
+	}
+	if got := mustLoadCookies(t, b); len(got) != 0 {
+		t.Errorf("expected no cookies after clear, got %v", got)
+	}
+	// The cookies of other endpoints are kept
+	if cookies, err := b.Load(other); err != nil || len(cookies) != 1 {
+		t.Errorf("expected the cookies of the other endpoint, got %v, error %v", cookies, err)
+	}
+}
+
+func TestCookieStoreKey(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+	}{
+		{"https://nuvla.io", "nuvla.io:443"},
+		{"https://nuvla.io/", "nuvla.io:443"},
+		{"https://Nuvla.IO:443/api", "nuvla.io:443"},
+		{"http://nuvla.io", "nuvla.io:80"},
+		{"http://localhost:8080", "localhost:8080"},
+		{"https://[::1]:8443", "::1:8443"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.endpoint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := cookieStoreKey(u); got != tt.want {
+			t.Errorf("cookieStoreKey(%s) = %s, expected %s", tt.endpoint, got, tt.want)
+		}
+	}
+}
+
+func TestCookieStore_EquivalentEndpoints(t *testing.T) {
+	stores := map[string]func(t *testing.T) CookieStore{
+		"memory": func(*testing.T) CookieStore { return NewMemoryCookieStore() },
+		"file":   func(t *testing.T) CookieStore { return NewFileCookieStore(filepath.Join(t.TempDir(), "cookies")) },
+	}
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			s := newStore(t)
+			saved, _ := url.Parse("https://nuvla.io")
+			same, _ := url.Parse("https://NUVLA.io:443/")
+			other, _ := url.Parse("https://nuvla.io:8443")
+
+			if err := s.Save(saved, []*http.Cookie{newSessionCookie("token", time.Hour)}); err != nil {
+				t.Fatal(err)
+			}
+			if cookies, err := s.Load(same); err != nil || cookieValues(cookies)[testSessionCookie] != "token" {
+				t.Errorf("expected the cookies of the equivalent endpoint, got %v, %v", cookies, err)
+			}
+			if cookies, err := s.Load(other); err != nil || len(cookies) != 0 {
+				t.Errorf("expected no cookies for another port, got %v, %v", cookies, err)
+			}
+
+			if err := s.Clear(same); err != nil {
+				t.Fatal(err)
+			}
+			if cookies, err := s.Load(saved); err != nil || len(cookies) != 0 {
+				t.Errorf("expected the cookies to be cleared through the equivalent endpoint, got %v, %v", cookies, err)
+			}
+		})
+	}
+}
+
+func TestFileCookieStore_HostKeyMigration(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies")
+	byHost, _ := json.Marshal(map[string][]*http.Cookie{
+		"nuvla.test":      {newSessionCookie("legacy", time.Hour)},
+		"nuvla.test:8443": {newSessionCookie("other-port", time.Hour)},
+	})
+	if err := os.WriteFile(path, byHost, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewFileCookieStore(path)
+	if got := mustLoadCookies(t, s); got[testSessionCookie] != "legacy" {
+		t.Errorf("expected the cookies stored by host, got %v", got)
+	}
+	// The next write moves them to the normalised key
+	mustSaveCookies(t, s, newSessionCookie("new", time.Hour))
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content map[string]json.RawMessage
+	if err := json.Unmarshal(b, &content); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := content["nuvla.test"]; ok {
+		t.Errorf("expected the host key to be replaced, got %s", b)
+	}
+	if _, ok := content["nuvla.test:443"]; !ok {
+		t.Errorf("expected the normalised key, got %s", b)
+	}
+	if _, ok := content["nuvla.test:8443"]; !ok {
+		t.Errorf("expected the cookies of other endpoints to be kept, got %s", b)
+	}
+}
+
+func TestFileCookieStore_SessionLogger(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	path := filepath.Join(t.TempDir(), "cookies")
+
+	var buf bytes.Buffer
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithCookieFile(path),
+		WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))))
+
+	// The store warns through the session logger that the unreadable file is overwritten
+	if err := os.WriteFile(path, []byte("not a cookie file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.LoginApiKeys(context.Background(), "credential/key", "secret"); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	if !strings.Contains(buf.String(), "Cannot read cookie file") {
+		t.Errorf("expected the cookie store warning in the session logger, got %q", buf.String())
+	}
+}
@@ -1,22 +1,30 @@
 package api_client_go
 
 import (
-	"bufio"
-	"encoding/json"
+	"fmt"
 	"github.com/nuvla/api-client-go/common"
-	"github.com/nuvla/api-client-go/types"
-	log "github.com/sirupsen/logrus"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
-	"os"
+	"sync"
+	"time"
 )
 
+// NuvlaCookies holds the cookies of a Nuvla session and persists them in a CookieStore. It implements
+// http.CookieJar and is safe for concurrent use.
 type NuvlaCookies struct {
+	// mu guards jar, lastCookie and sessionCookies
+	mu         sync.Mutex
 	jar        http.CookieJar
 	lastCookie []*http.Cookie
 	endpoint   *url.URL
 	cookieFile string
+	store      CookieStore
+
+	// sessionCookies keeps the cookies as set by the server, including their expiry, which the jar does not expose
+	sessionCookies map[string]*http.Cookie
+
+	log *common.PrintfLogger
 }
 
 // NewNuvlaCookies creates a new instance of the NuvlaCookies struct.
@@ -27,9 +35,9 @@ type NuvlaCookies struct {
 //   - endpoint (string): This is the URL endpoint for which the jar are relevant.
 //
 // The function does the following:
-// 1. Creates a new NuvlaCookies instance and sets the cookieFile field.
+// 1. Creates a new NuvlaCookies instance backed by a FileCookieStore on cookieFile.
 // 2. Parses the endpoint string into a url.URL object and sets the endpoint field of the NuvlaCookies instance.
-// 3. Checks if the cookieFile exists and is not empty. If it is, it creates a new cookiejar.Jar, attempts to load jar from the cookieFile into the cookiejar.Jar, and sets the jar field of the NuvlaCookies instance.
+// 3. Checks if the cookieFile exists and is not empty. If it is, it attempts to load the jar of the endpoint from the cookieFile.
 //
 // Returns:
 //   - A pointer to the newly created NuvlaCookies instance.
@@ -39,127 +47,214 @@ type NuvlaCookies struct {
 //	jar := client.NewNuvlaCookies("/path/to/jar.txt", "http://example.com")
 //	In this example, a new NuvlaCookies instance is created. The jar relevant to the "http://example.com" endpoint will be saved to or loaded from the "/path/to/jar.txt" file.
 func NewNuvlaCookies(cookieFile string, endpoint string) *NuvlaCookies {
-	// Create new NuvlaCookies
+	return loadFileNuvlaCookies(cookieFile, endpoint, common.DefaultLogger())
+}
+
+// loadFileNuvlaCookies loads the cookies from cookieFile, DefaultCookieFile if empty. If there is no default cookie
+// file, the cookies are kept in memory only.
+func loadFileNuvlaCookies(cookieFile string, endpoint string, logger *common.PrintfLogger) *NuvlaCookies {
+	if cookieFile == "" {
+		f, err := DefaultCookieFile()
+		if err != nil {
+			logger.Errorf("Cookies will not be persisted: %s", err)
+			return newNuvlaCookies(NewMemoryCookieStore(), endpoint, logger)
+		}
+		cookieFile = f
+	}
+	return loadNuvlaCookies(NewFileCookieStore(cookieFile), endpoint, logger)
+}
+
+// NewNuvlaCookiesWithStore creates a new NuvlaCookies instance persisting the cookies of endpoint in store.
+// The cookies already present in the store are loaded into the jar.
+func NewNuvlaCookiesWithStore(store CookieStore, endpoint string) *NuvlaCookies {
+	return loadNuvlaCookies(store, endpoint, common.DefaultLogger())
+}
+
+// loadNuvlaCookies creates a NuvlaCookies instance logging to logger and loads the cookies from the store
+func loadNuvlaCookies(store CookieStore, endpoint string, logger *common.PrintfLogger) *NuvlaCookies {
+	c := newNuvlaCookies(store, endpoint, logger)
+	if c == nil {
+		return nil
+	}
+	if fs, ok := store.(*FileCookieStore); ok {
+		c.cookieFile = fs.Path()
+		fs.setLogger(logger)
+	}
+
+	// Try to load jar from store
+	if err := c.load(); err != nil {
+		c.log.Infof("Error loading jar from cookie store: %s", err)
+	}
+	return c
+}
+
+// newNuvlaCookies creates a NuvlaCookies instance without loading the cookies from the store
+func newNuvlaCookies(store CookieStore, endpoint string, logger *common.PrintfLogger) *NuvlaCookies {
 	j, _ := cookiejar.New(nil)
 	c := &NuvlaCookies{
-		cookieFile: cookieFile,
-		jar:        j,
-	}
-	if c.cookieFile == "" {
-		c.cookieFile = types.DefaultCookieFile
+		jar:            j,
+		store:          store,
+		sessionCookies: make(map[string]*http.Cookie),
+		log:            logger,
 	}
 
 	// Parse endpoint
 	u, err := url.Parse(endpoint)
 	if err != nil {
-		log.Error("Error parsing endpoint URL")
+		logger.Error("Error parsing endpoint URL")
 		return nil
 	}
 	c.endpoint = u
-
-	// Try to load jar from file
-	if common.FileExistsAndNotEmpty(cookieFile) {
-		err := c.load()
-		if err != nil {
-			log.Info("Error loading jar from file")
-		}
-
-	}
-
 	return c
 }
 
 func (c *NuvlaCookies) load() error {
-	log.Debug("Loading cookies from file ...")
-	// Open the file
-	file, err := os.Open(c.cookieFile)
+	c.log.Debug("Loading cookies from store ...")
+	cookies, err := c.store.Load(c.endpoint)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	// Create a new scanner to read the file line by line
-	scanner := bufio.NewScanner(file)
+	// Replace the cookies of the jar: the ones missing from the store were deleted, possibly by another process
+	c.mu.Lock()
+	c.removeMissing(cookies)
+	c.jar.SetCookies(c.endpoint, cookies)
+	c.update(cookies)
+	c.mu.Unlock()
 
-	// Read the file line by line
-	for scanner.Scan() {
-		// Get the current line
-		line := scanner.Text()
+	c.log.Debug("Loading cookies from store... success")
+	return nil
+}
 
-		// Unmarshal the line into a cookie
-		var cookie http.Cookie
-		err := json.Unmarshal([]byte(line), &cookie)
-		if err != nil {
-			return err
+// removeMissing deletes the cookies of the endpoint which are not in cookies from the jar. The caller must hold c.mu.
+func (c *NuvlaCookies) removeMissing(cookies []*http.Cookie) {
+	keep := make(map[string]bool, len(cookies))
+	for _, cookie := range cookies {
+		keep[cookie.Name] = true
+	}
+	var deleted []*http.Cookie
+	for _, cookie := range c.jar.Cookies(c.endpoint) {
+		if keep[cookie.Name] {
+			continue
 		}
-
-		// Add the cookie to the jar
-		c.jar.SetCookies(c.endpoint, []*http.Cookie{&cookie})
+		path := "/"
+		if full, ok := c.sessionCookies[cookie.Name]; ok && full.Path != "" {
+			path = full.Path
+		}
+		deleted = append(deleted, &http.Cookie{Name: cookie.Name, Path: path, MaxAge: -1})
+		delete(c.sessionCookies, cookie.Name)
+	}
+	if len(deleted) > 0 {
+		c.jar.SetCookies(c.endpoint, deleted)
 	}
+}
 
-	// Check for errors during scanning
-	if err := scanner.Err(); err != nil {
+func (c *NuvlaCookies) Save() error {
+	c.mu.Lock()
+	cookies := c.cookiesToSave()
+	c.mu.Unlock()
+
+	if err := c.store.Save(c.endpoint, cookies); err != nil {
+		c.log.Errorf("Error saving cookies: %s", err)
 		return err
 	}
 
-	log.Debug("Loading cookies from file... success")
+	// Shared stores merge the cookies with the ones of other processes, which might be newer than ours
+	if _, ok := c.store.(sharedCookieStore); ok {
+		if err := c.load(); err != nil {
+			c.log.Warnf("Error reloading cookies after saving: %s", err)
+		}
+		c.setLastCookie()
+	}
+
+	c.log.Debugf("Cookies saved to store %s ... Success", c.storeName())
 	return nil
 }
 
-func (c *NuvlaCookies) Save() error {
-	// Create folder structure if required
-	// TODO: This check probably needs to be moved to a more appropriate place
-	err := common.BuildDirectoryStructureIfNotExists(types.DefaultConfigPath)
-	if err != nil {
+// Clear removes all the cookies from memory and from the store
+func (c *NuvlaCookies) Clear() error {
+	j, _ := cookiejar.New(nil)
+	c.mu.Lock()
+	c.jar = j
+	c.lastCookie = nil
+	c.sessionCookies = make(map[string]*http.Cookie)
+	c.mu.Unlock()
+
+	if err := c.store.Clear(c.endpoint); err != nil {
+		c.log.Errorf("Error removing cookies from store %s: %s", c.storeName(), err)
 		return err
 	}
+	c.log.Debugf("Cookies removed from store %s", c.storeName())
+	return nil
+}
 
-	// Create target file
-	cookieFile, err := os.Create(c.cookieFile)
-	if err != nil {
-		log.Errorf("Error creating file: %s", err)
-		return err
+// sharedCookieStore is implemented by stores which can be modified by other processes, such as FileCookieStore
+type sharedCookieStore interface {
+	HasChanged() bool
+}
+
+// ReloadIfChanged loads the cookies again if the store was modified by another process since it was last read
+// or written. It returns true if the cookies were reloaded.
+func (c *NuvlaCookies) ReloadIfChanged() bool {
+	shared, ok := c.store.(sharedCookieStore)
+	if !ok || !shared.HasChanged() {
+		return false
 	}
-	defer cookieFile.Close()
 
-	// Write jar to file
-	log.Infof("Cookies saved to file %s ... ", c.cookieFile)
-	for _, cookie := range c.jar.Cookies(c.endpoint) {
-		cookieJson, err := json.Marshal(cookie)
-		if err != nil {
-			log.Errorf("Error marshalling cookie: %s", err)
-			return err
-		}
-		_, err = cookieFile.Write(cookieJson)
-		if err != nil {
-			log.Errorf("Error writing cookie to file: %s", err)
-			return err
-		}
-		_, err = cookieFile.WriteString("\n")
-		if err != nil {
-			log.Errorf("Error writing new line to file: %s", err)
-			return err
-		}
+	c.log.Debugf("Cookie store %s changed, reloading cookies", c.storeName())
+	if err := c.load(); err != nil {
+		c.log.Warnf("Error reloading cookies: %s", err)
+		return false
 	}
+	// The loaded cookies are already in the store, no need to save them again
+	c.setLastCookie()
+	return true
+}
 
-	log.Infof("Cookies saved to file %s ... Success", c.cookieFile)
-	return nil
+func (c *NuvlaCookies) setLastCookie() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastCookie = c.jar.Cookies(c.endpoint)
+}
+
+// Cookies implements http.CookieJar
+func (c *NuvlaCookies) Cookies(u *url.URL) []*http.Cookie {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.jar.Cookies(u)
+}
+
+// SetCookies implements http.CookieJar
+func (c *NuvlaCookies) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.jar.SetCookies(u, cookies)
+}
+
+func (c *NuvlaCookies) storeName() string {
+	if c.cookieFile != "" {
+		return c.cookieFile
+	}
+	return fmt.Sprintf("%T", c.store)
 }
 
 // SaveIfNeeded jar if needed
 func (c *NuvlaCookies) SaveIfNeeded(newCookie http.CookieJar) error {
-	// Get cookies
+	// Get cookies. Read before locking, newCookie might be this NuvlaCookies itself
 	newCookies := newCookie.Cookies(c.endpoint)
 
 	// Compare jar
+	c.mu.Lock()
 	if !compareCookies(c.lastCookie, newCookies) {
-		log.Debugf("Cookies are different, saving new jar: %s", c.cookieFile)
+		c.log.Debugf("Cookies are different, saving new jar: %s", c.cookieFile)
 		// If jar are different, save new jar
 		c.jar.SetCookies(c.endpoint, newCookies)
 		c.lastCookie = newCookies
+		c.mu.Unlock()
 		return c.Save()
 	}
-	log.Debugf("Cookies are the same, not saving jar: %s", c.cookieFile)
+	c.mu.Unlock()
+	c.log.Debugf("Cookies are the same, not saving jar: %s", c.cookieFile)
 	return nil
 
 }
@@ -177,3 +272,74 @@ func compareCookies(cookies1, cookies2 []*http.Cookie) bool {
 
 	return true
 }
+
+// Update records the cookies set by the server, so that their expiry can be checked later.
+// Cookies deleted by the server (negative Max-Age or past expiry) are forgotten.
+func (c *NuvlaCookies) Update(cookies []*http.Cookie) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update(cookies)
+}
+
+func (c *NuvlaCookies) update(cookies []*http.Cookie) {
+	now := time.Now()
+	for _, cookie := range cookies {
+		stored := *cookie
+		if cookie.MaxAge > 0 {
+			stored.Expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+			stored.MaxAge = 0
+		}
+		if cookie.MaxAge < 0 || (!stored.Expires.IsZero() && stored.Expires.Before(now)) {
+			delete(c.sessionCookies, cookie.Name)
+			continue
+		}
+		c.sessionCookies[cookie.Name] = &stored
+	}
+}
+
+// Expiry returns the earliest expiry among the session cookies. The boolean is false if no cookie has an expiry.
+func (c *NuvlaCookies) Expiry() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expiry time.Time
+	for _, cookie := range c.sessionCookies {
+		if cookie.Expires.IsZero() {
+			continue
+		}
+		if expiry.IsZero() || cookie.Expires.Before(expiry) {
+			expiry = cookie.Expires
+		}
+	}
+	return expiry, !expiry.IsZero()
+}
+
+// HasValidSession returns true if there is at least one session cookie which has not expired yet.
+// Cookies without expiry are considered valid, since only the server can tell otherwise.
+func (c *NuvlaCookies) HasValidSession() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, cookie := range c.sessionCookies {
+		if cookie.Expires.IsZero() || cookie.Expires.After(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// cookiesToSave returns the cookies in the jar for the endpoint, completed with the attributes received from the
+// server (e.g. expiry) which the jar drops. The caller must hold c.mu.
+func (c *NuvlaCookies) cookiesToSave() []*http.Cookie {
+	jarCookies := c.jar.Cookies(c.endpoint)
+	cookies := make([]*http.Cookie, 0, len(jarCookies))
+	for _, cookie := range jarCookies {
+		if full, ok := c.sessionCookies[cookie.Name]; ok && full.Value == cookie.Value {
+			cookies = append(cookies, full)
+			continue
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies
+}
@@ -1 +1,71 @@
 package api_client_go
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNuvlaCookies_ReloadSharedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies")
+	endpoint := testCookieEndpoint.String()
+	a := NewNuvlaCookiesWithStore(NewFileCookieStore(path), endpoint)
+	b := NewNuvlaCookiesWithStore(NewFileCookieStore(path), endpoint)
+
+	// a logs in: b picks up the session instead of logging in too
+	session := newSessionCookie("session", time.Hour)
+	a.SetCookies(testCookieEndpoint, []*http.Cookie{session})
+	a.Update([]*http.Cookie{session})
+	if err := a.SaveIfNeeded(a); err != nil {
+		t.Fatalf("error saving cookies: %s", err)
+	}
+	if !b.ReloadIfChanged() || !b.HasValidSession() {
+		t.Fatal("expected the session of a after reload")
+	}
+
+	// a logs out: b drops the session
+	if err := a.Clear(); err != nil {
+		t.Fatalf("error clearing cookies: %s", err)
+	}
+	if !b.ReloadIfChanged() {
+		t.Fatal("expected a reload after logout")
+	}
+	if cookies := b.Cookies(testCookieEndpoint); len(cookies) != 0 || b.HasValidSession() {
+		t.Errorf("expected no session after logout, got %v", cookies)
+	}
+}
+
+func TestNuvlaCookies_HasValidSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		cookies []*http.Cookie
+		// expire moves the expiry of the stored cookies to the past, as if time went by
+		expire bool
+		want   bool
+	}{
+		{name: "no cookies"},
+		{name: "cookie not expired", cookies: []*http.Cookie{newSessionCookie("session", time.Hour)}, want: true},
+		{name: "cookie without expiry", cookies: []*http.Cookie{{Name: testSessionCookie, Value: "session", Path: "/"}}, want: true},
+		{name: "cookie with max age", cookies: []*http.Cookie{{Name: testSessionCookie, Value: "session", Path: "/", MaxAge: 60}}, want: true},
+		{name: "cookie expired since", cookies: []*http.Cookie{newSessionCookie("session", time.Hour)}, expire: true},
+		{name: "cookie deleted by the server", cookies: []*http.Cookie{{Name: testSessionCookie, Value: "", Path: "/", MaxAge: -1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewNuvlaCookiesWithStore(NewMemoryCookieStore(), testCookieEndpoint.String())
+			c.SetCookies(testCookieEndpoint, tt.cookies)
+			c.Update(tt.cookies)
+			if tt.expire {
+				c.mu.Lock()
+				for _, cookie := range c.sessionCookies {
+					cookie.Expires = time.Now().Add(-time.Minute)
+				}
+				c.mu.Unlock()
+			}
+			if got := c.HasValidSession(); got != tt.want {
+				t.Errorf("HasValidSession() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
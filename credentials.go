@@ -0,0 +1,93 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"github.com/nuvla/api-client-go/types"
+	"os"
+)
+
+// ErrNoCredentials is returned by credential providers when they cannot find any credentials
+var ErrNoCredentials = errors.New("no credentials found")
+
+// CredentialProvider supplies the log-in parameters used by NuvlaClient. It is consulted on every
+// re-authentication, so rotated secrets are picked up without restarting the process.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (types.LogInParams, error)
+}
+
+// CredentialProviderFunc adapts a function to the CredentialProvider interface
+type CredentialProviderFunc func(ctx context.Context) (types.LogInParams, error)
+
+func (f CredentialProviderFunc) Credentials(ctx context.Context) (types.LogInParams, error) {
+	return f(ctx)
+}
+
+// StaticCredentialProvider always returns the same credentials
+type StaticCredentialProvider struct {
+	Params types.LogInParams
+}
+
+func (p *StaticCredentialProvider) Credentials(_ context.Context) (types.LogInParams, error) {
+	if p.Params == nil {
+		return nil, ErrNoCredentials
+	}
+	return p.Params, nil
+}
+
+// EnvCredentialProvider reads the credentials from the environment variables NUVLA_API_KEY and NUVLA_API_SECRET
+// or, if not set, NUVLA_USERNAME and NUVLA_PASSWORD.
+type EnvCredentialProvider struct{}
+
+func (p *EnvCredentialProvider) Credentials(_ context.Context) (types.LogInParams, error) {
+	key, secret := os.Getenv(EnvApiKey), os.Getenv(EnvApiSecret)
+	if key != "" && secret != "" {
+		return types.NewApiKeyLogInParams(key, secret), nil
+	}
+	username, password := os.Getenv(EnvUsername), os.Getenv(EnvPassword)
+	if username != "" && password != "" {
+		return types.NewUserLogInParams(username, password), nil
+	}
+	return nil, ErrNoCredentials
+}
+
+// ProfileCredentialProvider reads the credentials of a profile from the configuration file, overlaid with the
+// environment variables. The file is read on every call.
+type ProfileCredentialProvider struct {
+	// ConfigFile is the path of the configuration file. If empty, DefaultConfigFile is used.
+	ConfigFile string
+	// Profile is the name of the profile. If empty, NUVLA_PROFILE or the default profile is used.
+	Profile string
+}
+
+func (p *ProfileCredentialProvider) Credentials(_ context.Context) (types.LogInParams, error) {
+	profile, err := LoadProfile(p.ConfigFile, p.Profile)
+	if err != nil {
+		return nil, err
+	}
+	params := profile.LogInParams()
+	if params == nil {
+		return nil, ErrNoCredentials
+	}
+	return params, nil
+}
+
+// ChainCredentialProvider returns the credentials of the first provider that finds some
+type ChainCredentialProvider []CredentialProvider
+
+func (c ChainCredentialProvider) Credentials(ctx context.Context) (types.LogInParams, error) {
+	var errs []error
+	for _, p := range c {
+		params, err := p.Credentials(ctx)
+		if err == nil {
+			return params, nil
+		}
+		if !errors.Is(err, ErrNoCredentials) {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(append([]error{ErrNoCredentials}, errs...)...)
+	}
+	return nil, ErrNoCredentials
+}
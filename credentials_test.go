@@ -0,0 +1,144 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+// recordingProvider returns its credentials or error and records that it was called
+func recordingProvider(name string, params types.LogInParams, err error, calls *[]string) CredentialProvider {
+	return CredentialProviderFunc(func(context.Context) (types.LogInParams, error) {
+		*calls = append(*calls, name)
+		return params, err
+	})
+}
+
+func TestChainCredentialProvider(t *testing.T) {
+	first := types.NewApiKeyLogInParams("credential/first", "secret")
+	second := types.NewUserLogInParams("second", "password")
+	readErr := errors.New("cannot read credentials")
+
+	tests := []struct {
+		name      string
+		providers func(calls *[]string) ChainCredentialProvider
+		want      types.LogInParams
+		wantCalls []string
+		wantErrs  []error
+	}{
+		{
+			name: "first provider wins",
+			providers: func(calls *[]string) ChainCredentialProvider {
+				return ChainCredentialProvider{
+					recordingProvider("first", first, nil, calls),
+					recordingProvider("second", second, nil, calls),
+				}
+			},
+			want:      first,
+			wantCalls: []string{"first"},
+		},
+		{
+			name: "falls through missing credentials",
+			providers: func(calls *[]string) ChainCredentialProvider {
+				return ChainCredentialProvider{
+					recordingProvider("first", nil, ErrNoCredentials, calls),
+					recordingProvider("second", second, nil, calls),
+				}
+			},
+			want:      second,
+			wantCalls: []string{"first", "second"},
+		},
+		{
+			name: "falls through errors",
+			providers: func(calls *[]string) ChainCredentialProvider {
+				return ChainCredentialProvider{
+					recordingProvider("first", nil, readErr, calls),
+					recordingProvider("second", second, nil, calls),
+				}
+			},
+			want:      second,
+			wantCalls: []string{"first", "second"},
+		},
+		{
+			name: "no provider has credentials",
+			providers: func(calls *[]string) ChainCredentialProvider {
+				return ChainCredentialProvider{
+					recordingProvider("first", nil, ErrNoCredentials, calls),
+					recordingProvider("second", nil, ErrNoCredentials, calls),
+				}
+			},
+			wantCalls: []string{"first", "second"},
+			wantErrs:  []error{ErrNoCredentials},
+		},
+		{
+			name: "errors are reported with missing credentials",
+			providers: func(calls *[]string) ChainCredentialProvider {
+				return ChainCredentialProvider{
+					recordingProvider("first", nil, readErr, calls),
+					recordingProvider("second", nil, ErrNoCredentials, calls),
+				}
+			},
+			wantCalls: []string{"first", "second"},
+			wantErrs:  []error{ErrNoCredentials, readErr},
+		},
+		{
+			name:      "empty chain",
+			providers: func(*[]string) ChainCredentialProvider { return nil },
+			wantErrs:  []error{ErrNoCredentials},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			params, err := tt.providers(&calls).Credentials(context.Background())
+			if params != tt.want {
+				t.Errorf("got credentials %v, want %v", params, tt.want)
+			}
+			if len(tt.wantErrs) == 0 && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			for _, wantErr := range tt.wantErrs {
+				if !errors.Is(err, wantErr) {
+					t.Errorf("expected error %v, got %v", wantErr, err)
+				}
+			}
+			if len(calls) != len(tt.wantCalls) {
+				t.Fatalf("expected calls %v, got %v", tt.wantCalls, calls)
+			}
+			for i := range calls {
+				if calls[i] != tt.wantCalls[i] {
+					t.Errorf("expected calls %v, got %v", tt.wantCalls, calls)
+				}
+			}
+		})
+	}
+}
+
+func TestChainCredentialProvider_EnvThenProfile(t *testing.T) {
+	unsetNuvlaEnv(t)
+	chain := ChainCredentialProvider{
+		&EnvCredentialProvider{},
+		&ProfileCredentialProvider{ConfigFile: writeTestConfigFile(t)},
+	}
+
+	params, err := chain.Credentials(context.Background())
+	if p, ok := params.(*types.ApiKeyLogInParams); err != nil || !ok || p.Key != "credential/file" {
+		t.Errorf("expected the profile credentials without environment, got %v, %v", params, err)
+	}
+
+	t.Setenv(EnvUsername, "env-user")
+	t.Setenv(EnvPassword, "env-password")
+	params, err = chain.Credentials(context.Background())
+	if p, ok := params.(*types.UserLogInParams); err != nil || !ok || p.Username != "env-user" {
+		t.Errorf("expected the environment credentials first, got %v, %v", params, err)
+	}
+
+	unsetNuvlaEnv(t)
+	chain[1] = &ProfileCredentialProvider{ConfigFile: filepath.Join(t.TempDir(), "missing.json")}
+	if _, err := chain.Credentials(context.Background()); !errors.Is(err, ErrNoCredentials) {
+		t.Errorf("expected no credentials, got %v", err)
+	}
+}
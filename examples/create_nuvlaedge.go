@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	nuvla "github.com/nuvla/api-client-go"
 	apiclientgo "github.com/nuvla/api-client-go/clients"
-	log "github.com/sirupsen/logrus"
+	"log/slog"
 	"os"
 )
 
@@ -33,23 +35,27 @@ func NewNuvlaBoxMinResourceData() map[string]interface{} {
 }
 
 func main() {
+	ctx := context.Background()
+	// The library logs nothing unless given a logger
+	nuvla.SetDefaultLogger(slog.Default())
+
 	c := apiclientgo.NewUserClient("https://nuvla.io", false, false)
-	err := c.LoginApiKeys("credential/<UUID>", "<SECRET>")
+	err := c.LoginApiKeys(ctx, "credential/<UUID>", "<SECRET>")
 	if err != nil {
 		fmt.Println(err)
 	}
 
-	resId, err := c.AddNuvlaEdge(NewNuvlaBoxMinResourceData())
+	resId, err := c.AddNuvlaEdge(ctx, NewNuvlaBoxMinResourceData())
 	if err != nil {
-		log.Errorf("Error creating NuvlaBox resource: %s", err)
+		slog.Error("Error creating NuvlaBox resource", "error", err)
 		os.Exit(1)
 	}
 	fmt.Printf("NuvlaBox resource ID: %s\n", resId)
 
-	res, err := c.GetNuvlaEdge(resId.String(), nil)
+	res, err := c.GetNuvlaEdge(ctx, resId.String(), nil)
 	if err != nil {
-		log.Errorf("Error getting NuvlaBox %s resource: %s", resId, err)
+		slog.Error("Error getting NuvlaBox resource", "id", resId, "error", err)
 		os.Exit(1)
 	}
-	log.Infof("NuvlaBox resource: %v", res.Data)
+	slog.Info("NuvlaBox resource", "data", res.Data)
 }
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package api_client_go
+
+import "os"
+
+const lockFileFlags = os.O_CREATE | os.O_RDWR
+
+// lockFile is a no-op on platforms without flock. Cookie files are still replaced atomically, but concurrent
+// processes may overwrite each other's cookies.
+func lockFile(_ *os.File, _ bool) error {
+	return nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build unix
+
+package api_client_go
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFileFlags opens the lock file without following symbolic links, so a link planted in place of the lock file
+// cannot be used to create or open another file
+const lockFileFlags = os.O_CREATE | os.O_RDWR | syscall.O_NOFOLLOW
+
+// lockFile acquires an advisory lock on f, blocking until it is available
+func lockFile(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if !errors.Is(err, syscall.EINTR) {
+			return err
+		}
+	}
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -2,15 +2,11 @@ module github.com/nuvla/api-client-go
 
 go 1.22
 
-require (
-	github.com/sirupsen/logrus v1.9.3
-	github.com/wI2L/jsondiff v0.6.0
-)
+require github.com/wI2L/jsondiff v0.6.0
 
 require (
 	github.com/tidwall/gjson v1.17.1 // indirect
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.1 // indirect
 	github.com/tidwall/sjson v1.2.5 // indirect
-	golang.org/x/sys v0.0.0-20220715151400-c0bba94af5f8 // indirect
 )
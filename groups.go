@@ -0,0 +1,65 @@
+package api_client_go
+
+import (
+	"context"
+	"fmt"
+	"github.com/nuvla/api-client-go/clients/resources"
+	"strings"
+)
+
+const switchGroupOperation = "switch-group"
+
+// SwitchGroup makes the current session act on behalf of the given group, e.g. "group/my-team". Resources created
+// afterwards are owned by the group. The new session cookie is persisted, so other clients sharing the cookie file
+// act on behalf of the group as well.
+func (nc *NuvlaClient) SwitchGroup(ctx context.Context, groupId string) (*resources.SessionResource, error) {
+	if !strings.HasPrefix(groupId, "group/") {
+		return nil, fmt.Errorf("invalid group id %s", groupId)
+	}
+	return nc.switchClaim(ctx, groupId)
+}
+
+// SwitchToUser makes the current session act on behalf of the logged-in user again
+func (nc *NuvlaClient) SwitchToUser(ctx context.Context) (*resources.SessionResource, error) {
+	session, err := nc.CurrentSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if session.ActiveClaim == session.User {
+		return session, nil
+	}
+	return nc.switchClaimFromSession(ctx, session, session.User)
+}
+
+// ListGroups returns the groups the current session can switch to
+func (nc *NuvlaClient) ListGroups(ctx context.Context) ([]string, error) {
+	session, err := nc.CurrentSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return session.GetGroups(), nil
+}
+
+func (nc *NuvlaClient) switchClaim(ctx context.Context, claim string) (*resources.SessionResource, error) {
+	session, err := nc.CurrentSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return nc.switchClaimFromSession(ctx, session, claim)
+}
+
+func (nc *NuvlaClient) switchClaimFromSession(ctx context.Context, session *resources.SessionResource, claim string) (*resources.SessionResource, error) {
+	nc.log.Debugf("Switching session %s active claim from %s to %s", session.Id, session.ActiveClaim, claim)
+	_, err := nc.Operation(ctx, session.Id, switchGroupOperation, map[string]interface{}{"claim": claim})
+	if err != nil {
+		nc.log.Errorf("Error switching session to %s: %s", claim, err)
+		return nil, err
+	}
+
+	if err := nc.saveCookies(); err != nil {
+		return nil, fmt.Errorf("error saving cookies after switching to %s: %w", claim, err)
+	}
+
+	// Retrieve the session again to confirm the new active claim
+	return nc.CurrentSession(ctx)
+}
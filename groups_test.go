@@ -0,0 +1,78 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+func TestNuvlaClient_ListGroups(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	c := newTestClient(t, s)
+
+	groups, err := c.ListGroups(context.Background())
+	if err != nil {
+		t.Fatalf("error listing groups: %s", err)
+	}
+	if want := []string{"group/team-a", "group/team-b"}; fmt.Sprint(groups) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, groups)
+	}
+
+	s.anonymous.Store(true)
+	if _, err := c.ListGroups(context.Background()); !errors.Is(err, types.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized without session, got %v", err)
+	}
+}
+
+func TestNuvlaClient_SwitchGroup(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	c := newTestClient(t, s)
+	ctx := context.Background()
+
+	session, err := c.SwitchGroup(ctx, "group/team-a")
+	if err != nil {
+		t.Fatalf("error switching group: %s", err)
+	}
+	if session.ActiveClaim != "group/team-a" || session.User != "user/1" {
+		t.Errorf("expected the session to act on behalf of group/team-a, got %+v", session)
+	}
+	// The requests after the switch carry the new session cookie
+	if _, err := c.Get(ctx, "nuvlabox/1", nil); err != nil {
+		t.Errorf("request after switching group failed: %s", err)
+	}
+
+	session, err = c.SwitchToUser(ctx)
+	if err != nil {
+		t.Fatalf("error switching back to the user: %s", err)
+	}
+	if session.ActiveClaim != "user/1" {
+		t.Errorf("expected the session to act on behalf of the user again, got %s", session.ActiveClaim)
+	}
+	if n := s.logins.Load(); n != 1 {
+		t.Errorf("expected no new login, got %d logins", n)
+	}
+}
+
+func TestNuvlaClient_SwitchGroupErrors(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	c := newTestClient(t, s)
+	ctx := context.Background()
+
+	searches := s.sessionSearches.Load()
+	if _, err := c.SwitchGroup(ctx, "user/2"); err == nil {
+		t.Error("expected an error switching to a non-group id")
+	}
+	if n := s.sessionSearches.Load(); n != searches {
+		t.Errorf("expected invalid group ids to be rejected without request, got %d searches", n-searches)
+	}
+
+	if _, err := c.SwitchGroup(ctx, "group/other"); !errors.Is(err, types.ErrForbidden) {
+		t.Errorf("expected ErrForbidden switching to a group the user is not member of, got %v", err)
+	}
+	if claim := s.activeClaim.Load(); claim != "user/1" {
+		t.Errorf("expected the active claim to be unchanged, got %s", claim)
+	}
+}
@@ -0,0 +1,28 @@
+package api_client_go
+
+import (
+	"github.com/nuvla/api-client-go/common"
+)
+
+// Logger is the logging interface of the client, implemented by *slog.Logger. The messages are passed without
+// attributes, once the API secrets, passwords, cookies and api-credentials they contain are redacted.
+type Logger = common.Logger
+
+// SetDefaultLogger sets the logger of the clients created without SessionOptions.Logger, and of the functions
+// not bound to a client. The library logs nothing until a logger is set.
+func SetDefaultLogger(l Logger) {
+	common.SetDefaultLogger(l)
+}
+
+// newSessionLogger returns the logger of a session configured with l
+func newSessionLogger(l Logger) *common.PrintfLogger {
+	if common.IsNilValueInterface(l) {
+		return common.DefaultLogger()
+	}
+	return common.NewPrintfLogger(l)
+}
+
+// Logger returns the logger of the session, for the clients built on top of it
+func (s *NuvlaSession) Logger() *common.PrintfLogger {
+	return s.log
+}
@@ -0,0 +1,129 @@
+package api_client_go
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+// RequestMetrics describes a request sent through NuvlaSession.Request, once its response body is consumed
+type RequestMetrics struct {
+	Method string
+	// ResourceType is parsed from the request endpoint, e.g. "nuvlabox" or "session" for logins
+	ResourceType string
+	// StatusCode is 0 if no response was received, in which case Err is set
+	StatusCode int
+	Err        error
+	// Duration is the time until the response headers were received
+	Duration time.Duration
+
+	// RequestBytes and RequestWireBytes are the sizes of the request body before and after compression
+	RequestBytes     int64
+	RequestWireBytes int64
+	// ResponseBytes and ResponseWireBytes are the sizes of the response body read after and before
+	// decompression. ResponseWireBytes is -1 if the transport decompressed the response on its own.
+	ResponseBytes     int64
+	ResponseWireBytes int64
+}
+
+// Metrics receives the measures of the client. It is the extension point for metrics libraries, see the
+// promnuvla package for Prometheus. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// ObserveRequest is called once per request, when its response body is read to the end or closed
+	ObserveRequest(m *RequestMetrics)
+	// ObserveReAuthentication is called after each re-authentication following a rejected request
+	ObserveReAuthentication(err error)
+}
+
+// observeRequest reports a request to the metrics, if configured
+func (s *NuvlaSession) observeRequest(m *RequestMetrics) {
+	if s.metrics != nil {
+		s.metrics.ObserveRequest(m)
+	}
+}
+
+// newRequestMetrics returns the measures of req known before it is sent
+func newRequestMetrics(reqInput *types.RequestOpts, req *http.Request, payloadSize int64) *RequestMetrics {
+	return &RequestMetrics{
+		Method:           reqInput.Method,
+		ResourceType:     newCallTarget(reqInput).resourceType,
+		RequestBytes:     payloadSize,
+		RequestWireBytes: req.ContentLength,
+	}
+}
+
+// measureResponse completes m with the response and reports it once the body is consumed. The body is only
+// counted, never modified.
+func (s *NuvlaSession) measureResponse(m *RequestMetrics, resp *http.Response) *http.Response {
+	if s.metrics == nil {
+		return resp
+	}
+
+	m.StatusCode = resp.StatusCode
+	if resp.Body == nil {
+		s.observeRequest(m)
+		return resp
+	}
+
+	body := &measuredBody{closer: resp.Body, reader: resp.Body}
+	var wireBytes func() int64
+	switch b := resp.Body.(type) {
+	case *gzipBody:
+		// Decompressed by the session, which counts the compressed bytes
+		wireBytes = func() int64 { return b.wire.n }
+	default:
+		wire := &countingReader{r: resp.Body}
+		body.reader = wire
+		wireBytes = func() int64 { return wire.n }
+		// The transport decompresses the responses to the requests which did not set Accept-Encoding
+		if resp.Uncompressed {
+			wireBytes = func() int64 { return -1 }
+		}
+	}
+	body.done = func(decoded int64) {
+		m.ResponseBytes = decoded
+		m.ResponseWireBytes = wireBytes()
+		s.observeRequest(m)
+	}
+	resp.Body = body
+	return resp
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// measuredBody counts the bytes read by the caller and calls done once the body is read to the end or closed
+type measuredBody struct {
+	closer io.Closer
+	reader io.Reader
+	n      int64
+	once   sync.Once
+	done   func(decoded int64)
+}
+
+func (b *measuredBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.once.Do(func() { b.done(b.n) })
+	}
+	return n, err
+}
+
+func (b *measuredBody) Close() error {
+	err := b.closer.Close()
+	b.once.Do(func() { b.done(b.n) })
+	return err
+}
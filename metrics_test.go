@@ -0,0 +1,166 @@
+package api_client_go
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+type recordingMetrics struct {
+	mu       sync.Mutex
+	requests []*RequestMetrics
+}
+
+func (r *recordingMetrics) ObserveRequest(m *RequestMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, m)
+}
+
+func (r *recordingMetrics) ObserveReAuthentication(error) {}
+
+func (r *recordingMetrics) last(t *testing.T) *RequestMetrics {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.requests) == 0 {
+		t.Fatal("no request observed")
+	}
+	return r.requests[len(r.requests)-1]
+}
+
+// sizeRecordingServer answers with a JSON document, gzip compressed if gzipResponse, and records the sizes of the
+// request and response bodies
+type sizeRecordingServer struct {
+	*httptest.Server
+	gzipResponse bool
+
+	requestWire, request, responseWire, response int
+}
+
+func newSizeRecordingServer(t *testing.T, gzipResponse bool) *sizeRecordingServer {
+	s := &sizeRecordingServer{gzipResponse: gzipResponse}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wire, _ := io.ReadAll(r.Body)
+		s.requestWire, s.request = len(wire), len(wire)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(bytes.NewReader(wire))
+			if err != nil {
+				t.Errorf("invalid gzip request body: %s", err)
+				return
+			}
+			plain, _ := io.ReadAll(gz)
+			s.request = len(plain)
+		}
+
+		body, _ := json.Marshal(map[string]interface{}{"id": "nuvlabox/1", "description": strings.Repeat("edge ", 100)})
+		s.response, s.responseWire = len(body), len(body)
+		w.Header().Set("Content-Type", "application/json")
+		if s.gzipResponse && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			var buf bytes.Buffer
+			gz := gzip.NewWriter(&buf)
+			_, _ = gz.Write(body)
+			_ = gz.Close()
+			body = buf.Bytes()
+			s.responseWire = len(body)
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		_, _ = w.Write(body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestMetrics_ByteCounters(t *testing.T) {
+	payload := map[string]interface{}{"description": strings.Repeat("updated ", 100)}
+	s := newSizeRecordingServer(t, false)
+	metrics := &recordingMetrics{}
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithoutPersistCookie, WithMetrics(metrics))
+
+	if _, err := c.Edit(context.Background(), "nuvlabox/1", payload, nil); err != nil {
+		t.Fatalf("edit failed: %s", err)
+	}
+	m := metrics.last(t)
+	if m.Method != http.MethodPut || m.ResourceType != "nuvlabox" || m.StatusCode != http.StatusOK || m.Err != nil {
+		t.Errorf("unexpected request measures: %+v", m)
+	}
+	if m.RequestBytes != int64(s.request) || m.RequestWireBytes != int64(s.requestWire) || s.request != s.requestWire {
+		t.Errorf("expected %d request bytes sent uncompressed, got %d and %d on the wire",
+			s.request, m.RequestBytes, m.RequestWireBytes)
+	}
+	if m.ResponseBytes != int64(s.response) || m.ResponseWireBytes != int64(s.responseWire) {
+		t.Errorf("expected response bytes %d and %d on the wire, got %d and %d",
+			s.response, s.responseWire, m.ResponseBytes, m.ResponseWireBytes)
+	}
+}
+
+func TestMetrics_RequestedGzipResponse(t *testing.T) {
+	s := newSizeRecordingServer(t, true)
+	metrics := &recordingMetrics{}
+	session := NewNuvlaSession(&SessionOptions{Endpoint: s.URL, Metrics: metrics})
+
+	resp, err := session.Request(context.Background(), &types.RequestOpts{
+		Method:   http.MethodGet,
+		Endpoint: s.URL + "/api/nuvlabox/1",
+		Headers:  map[string]string{"Accept-Encoding": "gzip"},
+	})
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	m := metrics.last(t)
+	if len(b) != s.response || m.ResponseBytes != int64(s.response) || m.ResponseWireBytes != int64(s.responseWire) {
+		t.Errorf("expected response bytes %d and %d on the wire, got %d and %d",
+			s.response, s.responseWire, m.ResponseBytes, m.ResponseWireBytes)
+	}
+	if m.ResponseWireBytes >= m.ResponseBytes {
+		t.Errorf("expected the compressed response to be smaller on the wire: %+v", m)
+	}
+}
+
+func TestMetrics_TransportDecompressedResponse(t *testing.T) {
+	s := newSizeRecordingServer(t, true)
+	metrics := &recordingMetrics{}
+	session := NewNuvlaSession(&SessionOptions{Endpoint: s.URL, Metrics: metrics})
+
+	// Without Accept-Encoding from the client, the transport asks for gzip and decompresses on its own
+	resp, err := session.Request(context.Background(), &types.RequestOpts{Method: http.MethodGet, Endpoint: s.URL + "/api/nuvlabox/1"})
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	m := metrics.last(t)
+	if len(b) != s.response || m.ResponseBytes != int64(s.response) || m.ResponseWireBytes != -1 {
+		t.Errorf("expected %d response bytes and no wire size, got %d and %d", s.response, m.ResponseBytes, m.ResponseWireBytes)
+	}
+	if m.RequestBytes != 0 || m.RequestWireBytes != 0 {
+		t.Errorf("expected no request body, got %d and %d", m.RequestBytes, m.RequestWireBytes)
+	}
+}
+
+func TestMetrics_RequestError(t *testing.T) {
+	s := newSizeRecordingServer(t, false)
+	s.Close()
+	metrics := &recordingMetrics{}
+	session := NewNuvlaSession(&SessionOptions{Endpoint: s.URL, Metrics: metrics})
+
+	if _, err := session.Request(context.Background(), &types.RequestOpts{Method: http.MethodGet, Endpoint: s.URL + "/api/nuvlabox"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if m := metrics.last(t); m.StatusCode != 0 || m.Err == nil || m.ResourceType != "nuvlabox" {
+		t.Errorf("unexpected measures of a failed request: %+v", m)
+	}
+}
@@ -0,0 +1,87 @@
+package api_client_go
+
+import (
+	"net/http"
+)
+
+// RoundTripFunc executes a single HTTP request and returns its response
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps a RoundTripFunc to add behaviour before and/or after a request is sent.
+// A middleware can mutate the request, short-circuit it by returning its own response or inspect the response.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// chainMiddlewares builds a RoundTripFunc where the first middleware is the outermost one.
+func chainMiddlewares(final RoundTripFunc, mws ...Middleware) RoundTripFunc {
+	rt := final
+	for i := len(mws) - 1; i >= 0; i-- {
+		if mws[i] == nil {
+			continue
+		}
+		rt = mws[i](rt)
+	}
+	return rt
+}
+
+// buildRoundTrip assembles the middleware chain for every request made through NuvlaSession.Request.
+// The order, from outermost to innermost, is:
+//  1. Cookie persistence: saves the jar when the response sets cookies
+//  2. Authentication header: adds the nuvla-authn-info header if configured, for the session or the request
+//  3. User middlewares, in the order they were registered
+//  4. The http.Client
+func (s *NuvlaSession) buildRoundTrip(userMiddlewares []Middleware) RoundTripFunc {
+	mws := []Middleware{
+		s.cookiePersistenceMiddleware,
+		s.authnHeaderMiddleware,
+	}
+	mws = append(mws, userMiddlewares...)
+
+	return chainMiddlewares(s.doRequest, mws...)
+}
+
+func (s *NuvlaSession) doRequest(req *http.Request) (*http.Response, error) {
+	resp, err := s.session.Do(req)
+	if err != nil {
+		s.log.Errorf("Error executing request: %s", err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *NuvlaSession) authnHeaderMiddleware(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		// Never send the identity to other hosts
+		if header := s.authnHeaderFor(req); header != "" && s.isEndpoint(req.URL) {
+			req.Header.Set(AuthnInfoHeader, header)
+		}
+		return next(req)
+	}
+}
+
+func (s *NuvlaSession) cookiePersistenceMiddleware(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		if s.persistCookie {
+			// Pick up the session of other processes sharing the cookie store
+			s.cookies.ReloadIfChanged()
+		}
+
+		resp, err := next(req)
+		if err != nil {
+			return resp, err
+		}
+
+		if resp.Header.Get("Set-Cookie") == "" {
+			return resp, nil
+		}
+
+		// Keep track of the cookie attributes, such as expiry, which the jar does not expose
+		s.cookies.Update(resp.Cookies())
+		if s.persistCookie {
+			// Save new jar
+			if err := s.cookies.SaveIfNeeded(s.session.Jar); err != nil {
+				s.log.Errorf("Error saving jar: %s", err)
+			}
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,111 @@
+package api_client_go
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// tracingMiddleware records when the requests enter and leave it
+func tracingMiddleware(name string, trace *[]string) Middleware {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			*trace = append(*trace, name+" before")
+			resp, err := next(req)
+			*trace = append(*trace, name+" after")
+			return resp, err
+		}
+	}
+}
+
+func TestChainMiddlewares_Order(t *testing.T) {
+	var trace []string
+	final := func(*http.Request) (*http.Response, error) {
+		trace = append(trace, "request")
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}
+	rt := chainMiddlewares(final, tracingMiddleware("first", &trace), nil, tracingMiddleware("second", &trace))
+
+	req, _ := http.NewRequest(http.MethodGet, "https://nuvla.io/api/cloud-entry-point", nil)
+	if _, err := rt(req); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []string{"first before", "second before", "request", "second after", "first after"}
+	if fmt.Sprint(trace) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, trace)
+	}
+}
+
+func TestNuvlaSession_MiddlewareOrder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": "nuvlabox/1"}`))
+	}))
+	defer srv.Close()
+
+	var trace []string
+	var authnHeader string
+	// The built-in middlewares are outer ones: the user middlewares see the identity header
+	inspect := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			authnHeader = req.Header.Get(AuthnInfoHeader)
+			return next(req)
+		}
+	}
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie,
+		WithAuthnInfo(NewAuthnInfo("user/1")),
+		WithMiddleware(tracingMiddleware("first", &trace), tracingMiddleware("second", &trace), inspect))
+
+	if _, err := c.Get(context.Background(), "nuvlabox/1", nil); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	want := []string{"first before", "second before", "second after", "first after"}
+	if fmt.Sprint(trace) != fmt.Sprint(want) {
+		t.Errorf("expected %v, got %v", want, trace)
+	}
+	if authnHeader != "user/1 user/1" {
+		t.Errorf("expected the identity header in the user middlewares, got %q", authnHeader)
+	}
+}
+
+func TestNuvlaSession_ShortCircuitMiddleware(t *testing.T) {
+	var serverRequests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		serverRequests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	var trace []string
+	cached := func(RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"id": "nuvlabox/1", "name": "cached"}`)),
+				Request:    req,
+			}, nil
+		}
+	}
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie,
+		WithMiddleware(tracingMiddleware("outer", &trace), cached, tracingMiddleware("inner", &trace)))
+
+	res, err := c.Get(context.Background(), "nuvlabox/1", nil)
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if res.Data["name"] != "cached" {
+		t.Errorf("expected the middleware response, got %v", res.Data)
+	}
+	if n := serverRequests.Load(); n != 0 {
+		t.Errorf("expected no request to the server, got %d", n)
+	}
+	if want := []string{"outer before", "outer after"}; fmt.Sprint(trace) != fmt.Sprint(want) {
+		t.Errorf("expected the inner middlewares to be skipped, got %v", trace)
+	}
+}
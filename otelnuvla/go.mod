@@ -0,0 +1,24 @@
+module github.com/nuvla/api-client-go/otelnuvla
+
+go 1.22
+
+require (
+	github.com/nuvla/api-client-go v0.9.1
+	go.opentelemetry.io/otel v1.28.0
+	go.opentelemetry.io/otel/trace v1.28.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/tidwall/gjson v1.17.1 // indirect
+	github.com/tidwall/match v1.1.1 // indirect
+	github.com/tidwall/pretty v1.2.1 // indirect
+	github.com/tidwall/sjson v1.2.5 // indirect
+	github.com/wI2L/jsondiff v0.6.0 // indirect
+	go.opentelemetry.io/otel/metric v1.28.0 // indirect
+)
+
+// The adapter is developed along with the client: it is built against the sources of the repository, and requires
+// the first release of the client providing nuvla.Tracer once it is tagged
+replace github.com/nuvla/api-client-go => ../
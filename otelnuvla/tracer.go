@@ -0,0 +1,120 @@
+// Package otelnuvla traces the Nuvla API client with OpenTelemetry. It is a separate module so the client does
+// not depend on OpenTelemetry.
+//
+//	client := nuvla.NewNuvlaClientFromOpts(creds, nuvla.WithTracer(otelnuvla.NewTracer()))
+package otelnuvla
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	nuvla "github.com/nuvla/api-client-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the spans
+const ScopeName = "github.com/nuvla/api-client-go"
+
+// Option configures the Tracer
+type Option func(*Tracer)
+
+// WithTracerProvider creates the spans with provider instead of the global one
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *Tracer) {
+		t.provider = provider
+	}
+}
+
+// WithPropagator propagates the trace context with propagator instead of the global one
+func WithPropagator(propagator propagation.TextMapPropagator) Option {
+	return func(t *Tracer) {
+		t.propagator = propagator
+	}
+}
+
+// Tracer implements nuvla.Tracer with OpenTelemetry
+type Tracer struct {
+	provider   trace.TracerProvider
+	propagator propagation.TextMapPropagator
+	tracer     trace.Tracer
+}
+
+// NewTracer returns a nuvla.Tracer using the global tracer provider and propagator of OpenTelemetry, unless set
+// through the options. If the global propagator is not configured, the W3C trace context is propagated.
+func NewTracer(opts ...Option) *Tracer {
+	t := &Tracer{}
+	for _, fn := range opts {
+		fn(t)
+	}
+	if t.provider == nil {
+		t.provider = otel.GetTracerProvider()
+	}
+	t.tracer = t.provider.Tracer(ScopeName)
+	return t
+}
+
+// Start implements nuvla.Tracer
+func (t *Tracer) Start(ctx context.Context, name string, kind nuvla.SpanKind, attrs ...nuvla.Attribute) (context.Context, nuvla.Span) {
+	spanKind := trace.SpanKindInternal
+	if kind == nuvla.SpanKindClient {
+		spanKind = trace.SpanKindClient
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(spanKind), trace.WithAttributes(convertAttributes(attrs)...))
+	return ctx, &otelSpan{span: span}
+}
+
+// Inject implements nuvla.Tracer
+func (t *Tracer) Inject(ctx context.Context, header http.Header) {
+	propagator := t.propagator
+	if propagator == nil {
+		// Resolved on every request as the global propagator can be set after the client is created
+		propagator = otel.GetTextMapPropagator()
+		if len(propagator.Fields()) == 0 {
+			propagator = propagation.TraceContext{}
+		}
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) SetAttributes(attrs ...nuvla.Attribute) {
+	s.span.SetAttributes(convertAttributes(attrs)...)
+}
+
+func (s *otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *otelSpan) End() {
+	s.span.End()
+}
+
+func convertAttributes(attrs []nuvla.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case int:
+			kvs = append(kvs, attribute.Int(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		case float64:
+			kvs = append(kvs, attribute.Float64(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
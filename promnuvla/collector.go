@@ -0,0 +1,158 @@
+// Package promnuvla exposes the metrics of the Nuvla API client to Prometheus. It is a separate module so the
+// client does not depend on Prometheus.
+//
+//	collector := promnuvla.NewCollector()
+//	prometheus.MustRegister(collector)
+//	client := nuvla.NewNuvlaClientFromOpts(creds, nuvla.WithMetrics(collector))
+package promnuvla
+
+import (
+	"strconv"
+
+	nuvla "github.com/nuvla/api-client-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Values of the stage label of the byte counters
+const (
+	StageUncompressed = "uncompressed"
+	StageWire         = "wire"
+)
+
+// Option configures the Collector
+type Option func(*options)
+
+type options struct {
+	namespace   string
+	constLabels prometheus.Labels
+	buckets     []float64
+}
+
+// WithNamespace prefixes the metric names with namespace instead of "nuvla"
+func WithNamespace(namespace string) Option {
+	return func(o *options) {
+		o.namespace = namespace
+	}
+}
+
+// WithConstLabels adds labels with a fixed value to every metric, e.g. the NuvlaEdge ID
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		o.constLabels = labels
+	}
+}
+
+// WithBuckets sets the buckets of the request duration histogram, in seconds
+func WithBuckets(buckets []float64) Option {
+	return func(o *options) {
+		o.buckets = buckets
+	}
+}
+
+// Collector implements nuvla.Metrics and prometheus.Collector
+type Collector struct {
+	requests      *prometheus.CounterVec
+	duration      *prometheus.HistogramVec
+	sentBytes     *prometheus.CounterVec
+	receivedBytes *prometheus.CounterVec
+	reAuths       *prometheus.CounterVec
+}
+
+// NewCollector returns a Collector to pass to nuvla.WithMetrics and register in a prometheus.Registerer
+func NewCollector(opts ...Option) *Collector {
+	o := &options{namespace: "nuvla", buckets: prometheus.DefBuckets}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	requestLabels := []string{"method", "resource_type"}
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Subsystem:   "client",
+			Name:        "requests_total",
+			Help:        "Requests sent to the Nuvla API, by status code. The code is \"error\" if no response was received.",
+			ConstLabels: o.constLabels,
+		}, append(requestLabels, "code")),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   o.namespace,
+			Subsystem:   "client",
+			Name:        "request_duration_seconds",
+			Help:        "Time until the response headers of the Nuvla API were received.",
+			ConstLabels: o.constLabels,
+			Buckets:     o.buckets,
+		}, requestLabels),
+		sentBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Subsystem:   "client",
+			Name:        "request_body_bytes_total",
+			Help:        "Bytes of the request bodies sent to the Nuvla API, before compression and on the wire.",
+			ConstLabels: o.constLabels,
+		}, append(requestLabels, "stage")),
+		receivedBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Subsystem:   "client",
+			Name:        "response_body_bytes_total",
+			Help:        "Bytes of the response bodies read from the Nuvla API, after decompression and on the wire.",
+			ConstLabels: o.constLabels,
+		}, append(requestLabels, "stage")),
+		reAuths: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   o.namespace,
+			Subsystem:   "client",
+			Name:        "reauthentications_total",
+			Help:        "Logins following a request rejected by the Nuvla API, by result.",
+			ConstLabels: o.constLabels,
+		}, []string{"result"}),
+	}
+}
+
+// ObserveRequest implements nuvla.Metrics
+func (c *Collector) ObserveRequest(m *nuvla.RequestMetrics) {
+	code := "error"
+	if m.StatusCode != 0 {
+		code = strconv.Itoa(m.StatusCode)
+	}
+	c.requests.WithLabelValues(m.Method, m.ResourceType, code).Inc()
+	c.duration.WithLabelValues(m.Method, m.ResourceType).Observe(m.Duration.Seconds())
+
+	if m.RequestBytes > 0 {
+		c.sentBytes.WithLabelValues(m.Method, m.ResourceType, StageUncompressed).Add(float64(m.RequestBytes))
+	}
+	if m.RequestWireBytes > 0 {
+		c.sentBytes.WithLabelValues(m.Method, m.ResourceType, StageWire).Add(float64(m.RequestWireBytes))
+	}
+	if m.ResponseBytes > 0 {
+		c.receivedBytes.WithLabelValues(m.Method, m.ResourceType, StageUncompressed).Add(float64(m.ResponseBytes))
+	}
+	// ResponseWireBytes is -1 if the transport decompressed the response on its own
+	if m.ResponseWireBytes > 0 {
+		c.receivedBytes.WithLabelValues(m.Method, m.ResourceType, StageWire).Add(float64(m.ResponseWireBytes))
+	}
+}
+
+// ObserveReAuthentication implements nuvla.Metrics
+func (c *Collector) ObserveReAuthentication(err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	c.reAuths.WithLabelValues(result).Inc()
+}
+
+// Describe implements prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.duration.Describe(ch)
+	c.sentBytes.Describe(ch)
+	c.receivedBytes.Describe(ch)
+	c.reAuths.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.duration.Collect(ch)
+	c.sentBytes.Collect(ch)
+	c.receivedBytes.Collect(ch)
+	c.reAuths.Collect(ch)
+}
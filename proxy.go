@@ -0,0 +1,155 @@
+package api_client_go
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyOptions configures the proxy used to reach the Nuvla endpoint. Without URL, the proxy is taken from the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+type ProxyOptions struct {
+	// URL of the proxy. The schemes "http", "https" and "socks5" are supported.
+	URL string `json:"url,omitempty"`
+	// Username and Password authenticate against the proxy. They take precedence over the credentials in URL.
+	Username string `json:"username,omitempty"`
+	Password string `json:"-"`
+	// NoProxy lists the hosts reached directly: host names, which also match their subdomains, ".domain" for
+	// subdomains only, IP addresses, CIDR ranges and "*" for all hosts. Entries may have a port, e.g. "host:8443".
+	NoProxy []string `json:"no-proxy,omitempty"`
+}
+
+// DialContextFunc opens the network connections of the client, e.g. through a Unix socket or a VPN interface.
+// It has the signature of net.Dialer.DialContext.
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// UnixSocketDialer returns a DialContextFunc connecting to the Unix socket at path, whatever the address requested
+func UnixSocketDialer(path string) DialContextFunc {
+	var d net.Dialer
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// proxyFunc returns the http.Transport Proxy function for the options
+func proxyFunc(opts *ProxyOptions) (func(*http.Request) (*url.URL, error), error) {
+	if opts == nil {
+		return http.ProxyFromEnvironment, nil
+	}
+
+	noProxy := newNoProxyMatcher(opts.NoProxy)
+	if opts.URL == "" {
+		return func(req *http.Request) (*url.URL, error) {
+			if noProxy.match(req.URL) {
+				return nil, nil
+			}
+			return http.ProxyFromEnvironment(req)
+		}, nil
+	}
+
+	proxyURL, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	if opts.Username != "" {
+		proxyURL.User = url.UserPassword(opts.Username, opts.Password)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if noProxy.match(req.URL) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// noProxyMatcher decides which hosts bypass the proxy
+type noProxyMatcher struct {
+	all     bool
+	entries []noProxyEntry
+	nets    []*net.IPNet
+}
+
+type noProxyEntry struct {
+	host string
+	port string
+	// subdomainsOnly is set for ".domain" entries
+	subdomainsOnly bool
+}
+
+func newNoProxyMatcher(list []string) *noProxyMatcher {
+	m := &noProxyMatcher{}
+	for _, entry := range list {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			m.all = true
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			m.nets = append(m.nets, ipNet)
+			continue
+		}
+
+		host, port := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			host, port = h, p
+		}
+		e := noProxyEntry{host: strings.Trim(host, "[]"), port: port}
+		if strings.HasPrefix(e.host, ".") {
+			e.host = strings.TrimPrefix(e.host, ".")
+			e.subdomainsOnly = true
+		}
+		m.entries = append(m.entries, e)
+	}
+	return m
+}
+
+func (m *noProxyMatcher) match(u *url.URL) bool {
+	if m.all {
+		return true
+	}
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(u.Scheme)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		for _, n := range m.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+	}
+
+	for _, e := range m.entries {
+		if e.port != "" && e.port != port {
+			continue
+		}
+		if host == e.host && !e.subdomainsOnly {
+			return true
+		}
+		if strings.HasSuffix(host, "."+e.host) {
+			return true
+		}
+	}
+	return false
+}
+
+func defaultPort(scheme string) string {
+	if scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
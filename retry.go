@@ -0,0 +1,183 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy defines how NuvlaClient retries requests that failed because of transient errors.
+// A nil policy, or a policy with MaxAttempts lower than 2, disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one
+	MaxAttempts int `json:"max-attempts"`
+	// BaseDelay is the delay before the first retry. It doubles with every attempt.
+	BaseDelay time.Duration `json:"base-delay"`
+	// MaxDelay caps the delay between attempts, including the one asked by a Retry-After header. Zero means no cap.
+	MaxDelay time.Duration `json:"max-delay"`
+	// Jitter is the fraction [0, 1] of the delay that is randomised to prevent synchronised retries
+	Jitter float64 `json:"jitter"`
+	// RetryableStatusCodes are the response status codes that trigger a retry
+	RetryableStatusCodes []int `json:"retryable-status-codes"`
+	// RetryNetworkErrors enables retries on connection resets, refused connections and timeouts
+	RetryNetworkErrors bool `json:"retry-network-errors"`
+	// RetryNonIdempotent allows retrying non-idempotent methods (POST, PATCH) such as operations and resource creation.
+	RetryNonIdempotent bool `json:"retry-non-idempotent"`
+}
+
+// DefaultRetryPolicy returns a policy retrying up to 3 times on 429, the 502, 503 and 504 gateway errors and
+// network errors. 500 is not retried as it usually reports a server bug, which repeating the request does not fix.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		Jitter:      0.2,
+		RetryableStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryNetworkErrors: true,
+		RetryNonIdempotent: false,
+	}
+}
+
+func (p *RetryPolicy) enabled() bool {
+	return p != nil && p.MaxAttempts > 1
+}
+
+// isIdempotentMethod reports whether the HTTP method can be safely repeated. Nuvla searches use PUT, so they are
+// covered as well.
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *RetryPolicy) isRetryableStatus(statusCode int) bool {
+	for _, c := range p.RetryableStatusCodes {
+		if c == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetryableError returns true for transient network errors. Context cancellations are never retried.
+func isRetryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return false
+}
+
+// shouldRetry decides whether the result of an attempt can be retried according to the policy
+func (p *RetryPolicy) shouldRetry(method string, resp *http.Response, err error) bool {
+	if !p.RetryNonIdempotent && !isIdempotentMethod(method) {
+		return false
+	}
+	if err != nil {
+		return p.RetryNetworkErrors && isRetryableError(err)
+	}
+	return resp != nil && p.isRetryableStatus(resp.StatusCode)
+}
+
+// backoff computes the exponential delay with jitter for the given attempt, starting at 1
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.BaseDelay) * math.Pow(2, float64(attempt-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		j := math.Min(p.Jitter, 1)
+		d = d*(1-j) + d*j*rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// delay returns the time to wait before the next attempt. The Retry-After header of the response, if present,
+// takes precedence over the backoff when it asks for a longer wait, within MaxDelay.
+func (p *RetryPolicy) delay(attempt int, resp *http.Response) time.Duration {
+	d := p.backoff(attempt)
+	if resp != nil {
+		if ra, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && ra > d {
+			d = ra
+		}
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// parseRetryAfter parses the Retry-After header in both of its formats: delay-seconds and HTTP-date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// exceedsDeadline returns true if waiting d would go past the context deadline
+func exceedsDeadline(ctx context.Context, d time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return ok && time.Now().Add(d).After(deadline)
+}
+
+// sleepContext waits for d or until the context is done, whichever happens first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// drainAndClose discards the remaining body so the underlying connection can be reused
+func drainAndClose(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+}
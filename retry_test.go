@@ -0,0 +1,223 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"0", 0, true},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"1.5", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %t, expected %s, %t", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got, ok := parseRetryAfter(date); !ok || got <= 58*time.Second || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s, %t, expected about a minute", date, got, ok)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := p.backoff(i + 1); got != w {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, w, got)
+		}
+	}
+	// Far attempts must not overflow past the cap
+	if got := p.backoff(100); got != time.Second {
+		t.Errorf("attempt 100: expected %s, got %s", time.Second, got)
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := p.backoff(5); got < 500*time.Millisecond || got > time.Second {
+			t.Fatalf("jittered delay %s out of [500ms, 1s]", got)
+		}
+	}
+
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxDelay   time.Duration
+		retryAfter string
+		attempt    int
+		want       time.Duration
+	}{
+		{"backoff without header", time.Minute, "", 2, 200 * time.Millisecond},
+		{"longer Retry-After wins", time.Minute, "5", 1, 5 * time.Second},
+		{"shorter Retry-After ignored", time.Minute, "0", 2, 200 * time.Millisecond},
+		{"Retry-After clamped to MaxDelay", time.Second, "5", 1, time.Second},
+		{"Retry-After date clamped to MaxDelay", time.Second, time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 1, time.Second},
+		{"Retry-After not clamped without MaxDelay", 0, "3600", 1, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: tt.maxDelay}
+			resp := &http.Response{Header: http.Header{}}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			if got := p.delay(tt.attempt, resp); got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExceedsDeadline(t *testing.T) {
+	if exceedsDeadline(context.Background(), time.Hour) {
+		t.Error("no deadline must never be exceeded")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if exceedsDeadline(ctx, time.Second) {
+		t.Error("1s must not exceed a deadline in 1m")
+	}
+	if !exceedsDeadline(ctx, time.Hour) {
+		t.Error("1h must exceed a deadline in 1m")
+	}
+}
+
+func TestNuvlaClient_Retry(t *testing.T) {
+	var attempts atomic.Int32
+	var status atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(int(status.Load()))
+		_, _ = w.Write([]byte(`{"message":"failure"}`))
+	}))
+	defer srv.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie, WithRetryPolicy(policy))
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		status   int
+		call     func() (*http.Response, error)
+		attempts int32
+	}{
+		{"PUT retried on 503", http.StatusServiceUnavailable, func() (*http.Response, error) {
+			return c.Put(ctx, "nuvlabox/1", map[string]interface{}{"name": "retry"}, nil)
+		}, 4},
+		{"PUT not retried on 500", http.StatusInternalServerError, func() (*http.Response, error) {
+			return c.Put(ctx, "nuvlabox/1", map[string]interface{}{"name": "retry"}, nil)
+		}, 1},
+		{"POST not retried on 503", http.StatusServiceUnavailable, func() (*http.Response, error) {
+			return c.Post(ctx, "nuvlabox", map[string]interface{}{"name": "retry"})
+		}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts.Store(0)
+			status.Store(int32(tt.status))
+			r, err := tt.call()
+			if err == nil {
+				_ = r.Body.Close()
+			}
+			if n := attempts.Load(); n != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, n)
+			}
+		})
+	}
+
+	// Unless explicitly allowed
+	policy.RetryNonIdempotent = true
+	c = NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie, WithRetryPolicy(policy))
+	attempts.Store(0)
+	status.Store(http.StatusServiceUnavailable)
+	if r, err := c.Post(ctx, "nuvlabox", map[string]interface{}{"name": "retry"}); err == nil {
+		_ = r.Body.Close()
+	}
+	if n := attempts.Load(); n != 4 {
+		t.Errorf("expected 4 attempts of POST with RetryNonIdempotent, got %d", n)
+	}
+}
+
+func TestNuvlaClient_RetryAfter(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if attempts.Add(1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message":"slow down"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"nuvlabox/1"}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		maxDelay time.Duration
+		timeout  time.Duration
+		attempts int32
+		status   int
+	}{
+		// The hour asked by the server is cut down to MaxDelay
+		{"clamped to MaxDelay", 10 * time.Millisecond, 0, 2, http.StatusOK},
+		// Waiting would go past the deadline: the 429 is returned right away
+		{"beyond the deadline", 0, 5 * time.Second, 1, http.StatusTooManyRequests},
+		{"clamped delay beyond the deadline", 30 * time.Second, 5 * time.Second, 1, http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts.Store(0)
+			policy := DefaultRetryPolicy()
+			policy.MaxDelay = tt.maxDelay
+			c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie, WithRetryPolicy(policy))
+
+			ctx := context.Background()
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+			start := time.Now()
+			_, err := c.Get(ctx, "nuvlabox/1", nil)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("expected no long wait, took %s", elapsed)
+			}
+			var apiErr *types.NuvlaAPIError
+			switch {
+			case tt.status == http.StatusOK && err != nil:
+				t.Errorf("request failed: %s", err)
+			case tt.status != http.StatusOK && (!errors.As(err, &apiErr) || apiErr.StatusCode != tt.status):
+				t.Errorf("expected status %d, got %v", tt.status, err)
+			}
+			if n := attempts.Load(); n != tt.attempts {
+				t.Errorf("expected %d attempts, got %d", tt.attempts, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nuvla/api-client-go/cimi"
+)
+
+// DefaultSearchPageSize is the page size used by SearchAll when none is provided
+const DefaultSearchPageSize = 100
+
+// ErrUnsupportedOrderBy is returned by SearchIterator.Err when SearchAll is given an ordering other than by id
+var ErrUnsupportedOrderBy = errors.New("search iterator only supports ordering by ascending id")
+
+// SearchIterator walks through every resource of a collection matching a search, fetching pages on demand.
+// Usage:
+//
+//	it := client.SearchAll(ctx, "nuvlabox", opts, 100)
+//	for it.Next() {
+//		res := it.Resource()
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type SearchIterator struct {
+	nc           *NuvlaClient
+	ctx          context.Context
+	resourceType string
+	opts         SearchOptions
+	pageSize     int
+	callOpts     []CallOption
+
+	page    []map[string]interface{}
+	pos     int
+	current map[string]interface{}
+	lastId  string
+	last    bool
+	err     error
+}
+
+// SearchAll returns an iterator over all the resources of resourceType matching opts. Pages are requested with
+// keyset pagination ordered by id, so resources added or removed during the walk neither shift pages nor cause
+// skipped or duplicated entries. Because of that, opts.First and opts.Last are ignored, and opts.OrderBy must be
+// empty or ascending by id: the iterator fails with ErrUnsupportedOrderBy otherwise. The walk stops early when ctx
+// is done. The call options apply to every page request.
+func (nc *NuvlaClient) SearchAll(ctx context.Context, resourceType string, opts *SearchOptions, pageSize int, callOpts ...CallOption) *SearchIterator {
+	if opts == nil {
+		opts = NewDefaultSearchOptions()
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultSearchPageSize
+	}
+
+	it := &SearchIterator{
+		nc:           nc,
+		ctx:          ctx,
+		resourceType: resourceType,
+		opts:         *opts,
+		pageSize:     pageSize,
+		callOpts:     callOpts,
+	}
+	switch it.opts.OrderBy {
+	case "", "id", cimi.Asc("id").String():
+	default:
+		it.err = fmt.Errorf("%w: %s", ErrUnsupportedOrderBy, it.opts.OrderBy)
+	}
+	// The id is required to request the next page
+	if len(it.opts.Select) > 0 && !containsString(it.opts.Select, "id") {
+		it.opts.Select = append(append([]string{}, it.opts.Select...), "id")
+	}
+	return it
+}
+
+// Next advances the iterator to the next resource. It returns false when there are no more resources or an error
+// occurred, which is then available through Err.
+func (it *SearchIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+	if err := it.ctx.Err(); err != nil {
+		it.err = err
+		return false
+	}
+
+	if it.pos >= len(it.page) {
+		if it.last {
+			return false
+		}
+		if err := it.fetchPage(); err != nil {
+			it.err = err
+			return false
+		}
+		if len(it.page) == 0 {
+			return false
+		}
+	}
+
+	it.current = it.page[it.pos]
+	it.pos++
+	return true
+}
+
+// Resource returns the current resource
+func (it *SearchIterator) Resource() map[string]interface{} {
+	return it.current
+}
+
+// Err returns the first error encountered while iterating
+func (it *SearchIterator) Err() error {
+	return it.err
+}
+
+func (it *SearchIterator) fetchPage() error {
+	opts := it.opts
+	opts.First = 1
+	opts.Last = it.pageSize
+	opts.OrderBy = cimi.Asc("id").String()
+	opts.Filter = it.pageFilter()
+
+	collection, err := it.nc.Search(it.ctx, it.resourceType, &opts, it.callOpts...)
+	if err != nil {
+		return err
+	}
+
+	it.page = collection.Resources
+	it.pos = 0
+	// Count is the number of resources after the last seen id, so this is the last page if it holds all of them
+	if len(it.page) < it.pageSize || collection.Count <= len(it.page) {
+		it.last = true
+	}
+	if len(it.page) == 0 {
+		return nil
+	}
+
+	id, ok := it.page[len(it.page)-1]["id"].(string)
+	if !ok || id == "" {
+		return fmt.Errorf("resource without id found while paginating %s", it.resourceType)
+	}
+	it.lastId = id
+	return nil
+}
+
+// pageFilter combines the user filter with the keyset condition on the last seen id
+func (it *SearchIterator) pageFilter() string {
+	if it.lastId == "" {
+		return it.opts.Filter
+	}
+	var userFilter cimi.Filter
+	if it.opts.Filter != "" {
+		userFilter = cimi.Raw(it.opts.Filter)
+	}
+	return cimi.And(userFilter, cimi.Gt("id", it.lastId)).String()
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
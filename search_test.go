@@ -0,0 +1,152 @@
+package api_client_go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+var (
+	testStateFilter = regexp.MustCompile(`state='(\w+)'`)
+	testKeysetAfter = regexp.MustCompile(`id>'([^']+)'`)
+)
+
+// testCollectionServer serves a nuvlabox collection, understanding the state filter and the keyset condition of
+// SearchIterator
+type testCollectionServer struct {
+	*httptest.Server
+	t        *testing.T
+	pageSize int
+
+	mu        sync.Mutex
+	resources map[string]string // id to state
+	pages     int
+	// onPage is called after serving each page, to modify the collection during the walk
+	onPage func(page int)
+}
+
+func newTestCollectionServer(t *testing.T, pageSize int) *testCollectionServer {
+	s := &testCollectionServer{t: t, pageSize: pageSize, resources: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testCollectionServer) add(n int, state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources[fmt.Sprintf("nuvlabox/%04d", n)] = state
+}
+
+func (s *testCollectionServer) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Method != http.MethodPut || r.URL.Path != "/api/nuvlabox" {
+		s.t.Errorf("unexpected search request %s %s: %v", r.Method, r.URL, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.Form.Get("orderby") != "id:asc" || r.Form.Get("first") != "1" || r.Form.Get("last") != strconv.Itoa(s.pageSize) {
+		s.t.Errorf("unexpected paging parameters: %v", r.Form)
+	}
+
+	filter := r.Form.Get("filter")
+	state := testStateFilter.FindStringSubmatch(filter)
+	after := testKeysetAfter.FindStringSubmatch(filter)
+
+	s.mu.Lock()
+	var ids []string
+	for id, st := range s.resources {
+		if state != nil && st != state[1] {
+			continue
+		}
+		if after != nil && id <= after[1] {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	count := len(ids)
+	if len(ids) > s.pageSize {
+		ids = ids[:s.pageSize]
+	}
+	page := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		page = append(page, map[string]interface{}{"id": id, "state": s.resources[id]})
+	}
+	s.pages++
+	pages, onPage := s.pages, s.onPage
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": count, "resources": page})
+	if onPage != nil {
+		onPage(pages)
+	}
+}
+
+func TestSearchAll_KeysetPagination(t *testing.T) {
+	const pageSize = 5
+	s := newTestCollectionServer(t, pageSize)
+	var want []string
+	for i := 0; i < 40; i++ {
+		state := "COMMISSIONED"
+		if i%3 == 0 {
+			state = "DECOMMISSIONED"
+		}
+		s.add(i*10, state)
+		if state == "COMMISSIONED" {
+			want = append(want, fmt.Sprintf("nuvlabox/%04d", i*10))
+		}
+	}
+	// Resources added during the walk before the current page must not shift the pages
+	s.onPage = func(page int) {
+		if page == 2 {
+			s.add(1, "COMMISSIONED")
+			s.add(2, "COMMISSIONED")
+		}
+	}
+
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithoutPersistCookie)
+	opts := &SearchOptions{Filter: "state='COMMISSIONED'", OrderBy: "id:asc"}
+	it := c.SearchAll(context.Background(), "nuvlabox", opts, pageSize)
+
+	var got []string
+	seen := make(map[string]bool)
+	for it.Next() {
+		id := it.Resource()["id"].(string)
+		if seen[id] {
+			t.Errorf("duplicate resource %s", id)
+		}
+		seen[id] = true
+		got = append(got, id)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration failed: %s", err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected %d resources in order without gaps:\n%v\ngot %d:\n%v", len(want), want, len(got), got)
+	}
+	if expected := (len(want) + pageSize - 1) / pageSize; s.pages != expected {
+		t.Errorf("expected %d pages, got %d", expected, s.pages)
+	}
+}
+
+func TestSearchAll_UnsupportedOrderBy(t *testing.T) {
+	s := newTestCollectionServer(t, DefaultSearchPageSize)
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithoutPersistCookie)
+
+	it := c.SearchAll(context.Background(), "nuvlabox", &SearchOptions{OrderBy: "created:desc"}, 0)
+	if it.Next() || !errors.Is(it.Err(), ErrUnsupportedOrderBy) {
+		t.Errorf("expected ErrUnsupportedOrderBy, got %v", it.Err())
+	}
+	if s.pages != 0 {
+		t.Errorf("expected no request, got %d", s.pages)
+	}
+}
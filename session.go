@@ -4,16 +4,13 @@ import (
 	"bytes"
 	"compress/gzip"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"github.com/nuvla/api-client-go/common"
 	"github.com/nuvla/api-client-go/types"
-	log "github.com/sirupsen/logrus"
 	"github.com/wI2L/jsondiff"
 	"io"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strconv"
 	"strings"
@@ -22,6 +19,7 @@ import (
 
 type NuvlaSession struct {
 	endpoint       string
+	endpointURL    *url.URL
 	insecure       bool
 	reauthenticate bool
 	persistCookie  bool
@@ -29,8 +27,22 @@ type NuvlaSession struct {
 	authnHeader    string
 	compress       bool
 	debug          bool
-
-	session *http.Client
+	retry          *RetryPolicy
+	middlewares    []Middleware
+	tls            *TLSOptions
+	proxy          *ProxyOptions
+	dialContext    DialContextFunc
+	transportOpts  *TransportOptions
+	timeout        time.Duration
+	httpClient     *http.Client
+	transport      http.RoundTripper
+	tracer         Tracer
+	metrics        Metrics
+	logger         Logger
+	log            *common.PrintfLogger
+
+	session   *http.Client
+	roundTrip RoundTripFunc
 
 	// Nuvla session data
 	cookies *NuvlaCookies
@@ -40,42 +52,68 @@ func SanitiseEndpoint(endpoint string) string {
 	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
 		return endpoint
 	} else {
-		log.Infof("Endpoint %s does not have a protocol. Assuming https", endpoint)
+		common.DefaultLogger().Infof("Endpoint %s does not have a protocol. Assuming https", endpoint)
 		return "https://" + endpoint
 	}
 }
 
 func NewNuvlaSession(sessionAttrs *SessionOptions) *NuvlaSession {
 
-	log.Debugf("Creating new Nuvla session for endpoint %s", sessionAttrs.Endpoint)
 	s := &NuvlaSession{
+		logger:         sessionAttrs.Logger,
+		log:            newSessionLogger(sessionAttrs.Logger),
 		endpoint:       SanitiseEndpoint(sessionAttrs.Endpoint),
+		insecure:       sessionAttrs.Insecure,
 		reauthenticate: sessionAttrs.ReAuthenticate,
 		persistCookie:  sessionAttrs.PersistCookie,
 		authnHeader:    sessionAttrs.AuthHeader,
 		debug:          sessionAttrs.Debug,
-		session: &http.Client{
-			Timeout: time.Second * types.DefaultTimeout,
-			Jar:     nil,
-		},
+		retry:          sessionAttrs.Retry,
+		middlewares:    sessionAttrs.Middlewares,
+		tls:            sessionAttrs.TLS,
+		proxy:          sessionAttrs.Proxy,
+		dialContext:    sessionAttrs.DialContext,
+		transportOpts:  sessionAttrs.TransportOpts,
+		timeout:        sessionAttrs.Timeout,
+		httpClient:     sessionAttrs.HTTPClient,
+		transport:      sessionAttrs.Transport,
+		tracer:         sessionAttrs.Tracer,
+		metrics:        sessionAttrs.Metrics,
+	}
+	s.log.Debugf("Creating new Nuvla session for endpoint %s", sessionAttrs.Endpoint)
+	if s.timeout == 0 {
+		s.timeout = types.DefaultTimeout * time.Second
 	}
 
-	if sessionAttrs.Insecure {
-		s.session.Transport = &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
+	if u, err := url.Parse(s.endpoint); err == nil {
+		s.endpointURL = u
+	} else {
+		s.log.Errorf("Error parsing endpoint URL %s: %s", s.endpoint, err)
 	}
 
+	client, err := newHTTPClient(sessionAttrs, s.log)
+	if err != nil {
+		s.log.Errorf("Error configuring the HTTP transport: %s", err)
+	}
+	client.CheckRedirect = s.checkRedirect(client.CheckRedirect)
+	s.session = client
+
 	// Try import jar
-	if sessionAttrs.PersistCookie {
-		s.cookies = NewNuvlaCookies(sessionAttrs.CookieFile, sessionAttrs.Endpoint)
-		s.session.Jar = s.cookies.jar
-	} else {
-		j, _ := cookiejar.New(nil)
-		s.session.Jar = j
+	switch {
+	case sessionAttrs.PersistCookie && sessionAttrs.CookieStore != nil:
+		s.cookies = loadNuvlaCookies(sessionAttrs.CookieStore, s.endpoint, s.log)
+	case sessionAttrs.PersistCookie:
+		s.cookies = loadFileNuvlaCookies(sessionAttrs.CookieFile, s.endpoint, s.log)
+	default:
+		// Cookies are still tracked in memory to know the session expiry
+		s.cookies = newNuvlaCookies(NewMemoryCookieStore(), s.endpoint, s.log)
 	}
+	// The jar is never replaced afterwards: NuvlaCookies swaps its internal jar on logout under its own lock
+	s.session.Jar = s.cookies
 	// Probably, check here if jar are GOOD
 
+	s.roundTrip = s.buildRoundTrip(s.middlewares)
+
 	return s
 }
 
@@ -83,11 +121,22 @@ func NewNuvlaSession(sessionAttrs *SessionOptions) *NuvlaSession {
 ************************ Credentials management **********************************************
 ****************************************************************************************/
 
+// NeedToLogin returns true if there are no session cookies or all of them have expired.
+// It only inspects the local cookies; use NuvlaClient.IsAuthenticatedContext to confirm the session with the server.
 func (s *NuvlaSession) NeedToLogin() bool {
-	return false
+	return s.cookies == nil || !s.cookies.HasValidSession()
+}
+
+// SessionExpiry returns the expiry of the session cookies, if the server provided one
+func (s *NuvlaSession) SessionExpiry() (time.Time, bool) {
+	if s.cookies == nil {
+		return time.Time{}, false
+	}
+	return s.cookies.Expiry()
 }
 
-func (s *NuvlaSession) login(loginParams types.LogInParams) error {
+// login creates a new session. The session timeout applies if ctx has no deadline.
+func (s *NuvlaSession) login(ctx context.Context, loginParams types.LogInParams) error {
 	// Build headers for login
 	h := make(map[string]string)
 	h["Content-Type"] = "application/json"
@@ -97,11 +146,8 @@ func (s *NuvlaSession) login(loginParams types.LogInParams) error {
 	p := make(map[string]interface{})
 	p["template"] = loginParams.GetParams()
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(common.DefaultRequestTimeout)*time.Second)
-	defer cancel()
-
 	// Send request
-	log.Debug("Sending login request...")
+	s.log.Debug("Sending login request...")
 	res, err := s.Request(ctx, &types.RequestOpts{
 		Method:   "POST",
 		Endpoint: s.endpoint + types.SessionEndpoint,
@@ -110,7 +156,7 @@ func (s *NuvlaSession) login(loginParams types.LogInParams) error {
 	})
 
 	if err != nil {
-		log.Errorf("Error logging in: %s", err)
+		s.log.Errorf("Error logging in: %s", err)
 		return err
 	}
 	defer func() {
@@ -118,8 +164,19 @@ func (s *NuvlaSession) login(loginParams types.LogInParams) error {
 	}()
 
 	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
-		log.Errorf("Error logging in: %s", res.Status)
-		return fmt.Errorf("error logging in: %s", res.Status)
+		s.log.Errorf("Error logging in: %s", res.Status)
+		return types.NewNuvlaAPIErrorFromResponse(res)
+	}
+
+	// Accounts with two-factor authentication get a callback to complete the login instead of a session
+	nuvlaRes, err := types.NewNuvlaResponseFromResponse(res)
+	if err != nil {
+		s.log.Debugf("Cannot decode login response: %s", err)
+		return nil
+	}
+	if challenge := newTwoFactorChallenge(nuvlaRes, loginParams); challenge != nil {
+		s.log.Infof("Login requires a two-factor authentication code: %s", challenge.Message)
+		return challenge
 	}
 
 	return nil
@@ -129,20 +186,6 @@ func (s *NuvlaSession) login(loginParams types.LogInParams) error {
 ************************ Request management **********************************************
 ****************************************************************************************/
 
-func (s *NuvlaSession) request(req *http.Request) (*http.Response, error) {
-	if s.authnHeader != "" {
-		req.Header.Add("nuvla-authn-info", s.authnHeader)
-	}
-
-	resp, err := s.session.Do(req)
-	if err != nil {
-		log.Errorf("Error executing request: %s", err)
-		return nil, err
-	}
-
-	return resp, nil
-}
-
 func addParamsToQuery(req *http.Request, input *types.RequestParams) {
 	if input.Select != nil {
 		q := req.URL.Query()
@@ -154,17 +197,59 @@ func addParamsToQuery(req *http.Request, input *types.RequestParams) {
 
 }
 
-func compressPayload(payload []byte) *bytes.Buffer {
+func compressPayload(payload []byte) ([]byte, error) {
 	var buf bytes.Buffer
 	gz := gzip.NewWriter(&buf)
 	if _, err := gz.Write(payload); err != nil {
-		log.Warn("Error compressing payload, returning uncompressed payload")
-		return bytes.NewBuffer(payload)
+		return nil, err
 	}
 	if err := gz.Close(); err != nil {
-		log.Errorf("Error closing gzip writer: %s", err)
+		return nil, err
 	}
-	return &buf
+	return buf.Bytes(), nil
+}
+
+// acceptsGzip tells whether the request asked for a gzip response explicitly
+func acceptsGzip(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept-Encoding"), "gzip")
+}
+
+// decompressResponse decompresses the gzip response of a request which asked for it explicitly: the transport
+// only decompresses the responses of the requests which did not set Accept-Encoding.
+func decompressResponse(resp *http.Response, requestedGzip bool) *http.Response {
+	if !requestedGzip || resp.Body == nil || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return resp
+	}
+	resp.Body = &gzipBody{wire: &countingReader{r: resp.Body}, closer: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp
+}
+
+// gzipBody decompresses a response body. The gzip header is read on the first Read, so an empty body is not an
+// error until it is read.
+type gzipBody struct {
+	// wire counts the compressed bytes
+	wire   *countingReader
+	closer io.Closer
+	gz     *gzip.Reader
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.gz == nil {
+		gz, err := gzip.NewReader(b.wire)
+		if err != nil {
+			return 0, err
+		}
+		b.gz = gz
+	}
+	return b.gz.Read(p)
+}
+
+func (b *gzipBody) Close() error {
+	return b.closer.Close()
 }
 
 // bodyTypeCompatible checks if the body content is compatible with the body type. Currently supported types are:
@@ -183,40 +268,55 @@ func bodyTypeCompatible(bodyContent interface{}) bool {
 	}
 }
 
-func encodeBody(request *http.Request, reqInput *types.RequestOpts, compress bool) error {
+// setRequestBody sets the already encoded payload as request body. GetBody allows the http.Client to replay the
+// payload on redirects and connection retries.
+func setRequestBody(request *http.Request, payload []byte) {
+	request.Body = io.NopCloser(bytes.NewReader(payload))
+	request.ContentLength = int64(len(payload))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(payload)), nil
+	}
+}
+
+// encodeBody sets the request body and returns its size before compression
+func (s *NuvlaSession) encodeBody(request *http.Request, reqInput *types.RequestOpts) (int64, error) {
 	if reqInput.JsonData == nil && reqInput.Data == nil {
-		return nil
+		return 0, nil
 	}
 
 	if reqInput.JsonData != nil && reqInput.Data != nil {
-		log.Warn("Both Data and JsonData provided, this could lead to unexpected behavior. Using JsonData")
+		s.log.Warn("Both Data and JsonData provided, this could lead to unexpected behavior. Using JsonData")
 	}
 
+	var size int64
 	if reqInput.JsonData != nil {
 		if !bodyTypeCompatible(reqInput.JsonData) {
-			log.Warnf("Unknown type %T for json payload", reqInput.JsonData)
-			return nil
+			s.log.Warnf("Unknown type %T for json payload", reqInput.JsonData)
+			return 0, nil
 		}
 
 		jsonPayload, err := json.Marshal(reqInput.JsonData)
 		if err != nil {
-			log.Errorf("Error marshalling json payload: %s", err)
-			return err
+			s.log.Errorf("Error marshalling json payload: %s", err)
+			return 0, err
 		}
-
-		var buffer *bytes.Buffer
-		if compress {
-			buffer = compressPayload(jsonPayload)
-		} else {
-			buffer = bytes.NewBuffer(jsonPayload)
+		size = int64(len(jsonPayload))
+
+		payload := jsonPayload
+		if s.compress {
+			if gzPayload, err := compressPayload(jsonPayload); err == nil {
+				payload = gzPayload
+				request.Header.Set("Content-Encoding", "gzip")
+			} else {
+				s.log.Warnf("Error compressing payload, sending it uncompressed: %s", err)
+			}
 		}
 		request.Header.Set("Content-Type", "application/json")
-		request.Body = io.NopCloser(buffer)
-		request.ContentLength = int64(buffer.Len())
+		setRequestBody(request, payload)
 	}
 
 	if reqInput.Data != nil {
-		log.Debug("Encoding data payload")
+		s.log.Debug("Encoding data payload")
 		data := url.Values{}
 		for k, value := range reqInput.Data {
 			switch v := value.(type) {
@@ -229,63 +329,92 @@ func encodeBody(request *http.Request, reqInput *types.RequestOpts, compress boo
 			case int:
 				data.Add(k, strconv.Itoa(v))
 			default:
-				log.Warnf("Unknown type %T for key %s", v, k)
+				s.log.Warnf("Unknown type %T for key %s", v, k)
 				data.Add(k, fmt.Sprintf("%v", v))
 			}
 		}
+		encoded := []byte(data.Encode())
+		size = int64(len(encoded))
+		request.Header.Del("Content-Encoding")
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-		request.Body = io.NopCloser(bytes.NewBufferString(data.Encode()))
+		setRequestBody(request, encoded)
 	}
-	return nil
+	return size, nil
 }
 
+// Request sends a single request. The session timeout applies if ctx has no deadline.
 func (s *NuvlaSession) Request(ctx context.Context, reqInput *types.RequestOpts) (*http.Response, error) {
 	// Build endpoint
-	log.Debugf("Sending [%s] request to endpoint: %s", reqInput.Method, reqInput.Endpoint)
+	s.log.Debugf("Sending [%s] request to endpoint: %s", reqInput.Method, reqInput.Endpoint)
+
+	cancel := func() {}
+	if _, ok := ctx.Deadline(); !ok {
+		ctx, cancel = withTimeout(ctx, s.timeout)
+	}
 
 	r, err := http.NewRequestWithContext(ctx, reqInput.Method, reqInput.Endpoint, nil)
 	if err != nil {
-		log.Errorf("Error creating request: %s", err)
+		cancel()
+		s.log.Errorf("Error creating request: %s", err)
 		return nil, err
 	}
 
 	// Encode body asserting from json or data encoded as URL
-	err = encodeBody(r, reqInput, s.compress)
+	payloadSize, err := s.encodeBody(r, reqInput)
 	if err != nil {
-		log.Errorf("Error encoding body: %s", err)
+		cancel()
+		s.log.Errorf("Error encoding body: %s", err)
 		return nil, err
 	}
 
 	for k, v := range reqInput.Headers {
-		log.Debugf("Adding header %s: %s", k, v)
+		s.log.Debugf("Adding header %s: %s", k, v)
 		r.Header.Set(k, v)
 	}
 
 	if reqInput.Params != nil {
 		addParamsToQuery(r, reqInput.Params)
 	}
+	if reqInput.AuthnHeader != "" {
+		r = r.WithContext(contextWithAuthnHeader(r.Context(), reqInput.AuthnHeader))
+	}
 
-	resp, err := s.request(r)
+	m := newRequestMetrics(reqInput, r, payloadSize)
+	start := time.Now()
+	r, span := s.startRequestSpan(r)
+	resp, err := s.roundTrip(r)
+	endSpan(span, resp, err)
+	m.Duration = time.Since(start)
 	if err != nil {
-		log.Errorf("Error executing request: %s", err)
+		cancel()
+		m.Err = err
+		s.observeRequest(m)
+		s.log.Errorf("Error executing request: %s", err)
 		return nil, err
 	}
+	resp = decompressResponse(resp, acceptsGzip(r))
+	return bindCancel(s.measureResponse(m, resp), cancel), nil
+}
 
-	if s.persistCookie && resp.Header.Get("Set-Cookie") != "" {
-		// Save new jar
-		err := s.cookies.SaveIfNeeded(s.session.Jar)
-		if err != nil {
-			log.Errorf("Error saving jar: %s", err)
-		}
+// saveCookies persists the current jar if cookie persistence is enabled
+func (s *NuvlaSession) saveCookies() error {
+	if !s.persistCookie || s.cookies == nil {
+		return nil
 	}
-	return resp, nil
+	return s.cookies.SaveIfNeeded(s.session.Jar)
 }
 
 func (s *NuvlaSession) logout() error {
-	log.Infof("Logging out from %s", s.endpoint)
-	// TODO: Remove cookies if present
-	// For the moment, lets release unused connections...
+	s.log.Infof("Logging out from %s", s.endpoint)
+	// Release unused connections
 	s.session.CloseIdleConnections()
+
+	if s.cookies == nil {
+		return nil
+	}
+	if err := s.cookies.Clear(); err != nil {
+		return fmt.Errorf("error removing cookies: %w", err)
+	}
 	return nil
 }
 
@@ -306,10 +435,23 @@ func (s *NuvlaSession) GetSessionOpts() SessionOptions {
 		AuthHeader:     s.authnHeader,
 		Debug:          s.debug,
 		Compress:       s.compress,
+		Retry:          s.retry,
+		Middlewares:    s.middlewares,
+		TLS:            s.tls,
+		Proxy:          s.proxy,
+		DialContext:    s.dialContext,
+		TransportOpts:  s.transportOpts,
+		Timeout:        s.timeout,
+		HTTPClient:     s.httpClient,
+		Transport:      s.transport,
+		Tracer:         s.tracer,
+		Metrics:        s.metrics,
+		Logger:         s.logger,
 	}
 	if s.persistCookie && s.cookies != nil {
 		opts.PersistCookie = s.persistCookie
 		opts.CookieFile = s.cookies.cookieFile
+		opts.CookieStore = s.cookies.store
 	}
 
 	return opts
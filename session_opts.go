@@ -2,6 +2,8 @@ package api_client_go
 
 import (
 	"github.com/nuvla/api-client-go/types"
+	"net/http"
+	"time"
 )
 
 type SessionOptFunc func(*SessionOptions)
@@ -11,10 +13,43 @@ type SessionOptions struct {
 	Insecure       bool   `json:"insecure"`
 	ReAuthenticate bool   `json:"re-authenticate"`
 	PersistCookie  bool   `json:"persist-cookie"`
-	CookieFile     string `json:"cookie-file"`
-	AuthHeader     string `json:"auth-header"`
-	Compress       bool   `json:"compress"`
-	Debug          bool   `json:"debug"`
+	// CookieFile defaults to DefaultCookieFile. It can be shared by several processes, which then share the
+	// session and the identity of the last one which logged in. Use a file per identity.
+	CookieFile string `json:"cookie-file"`
+	AuthHeader string `json:"auth-header"`
+	Compress   bool   `json:"compress"`
+	Debug      bool   `json:"debug"`
+
+	Retry *RetryPolicy  `json:"retry,omitempty"`
+	TLS   *TLSOptions   `json:"tls,omitempty"`
+	Proxy *ProxyOptions `json:"proxy,omitempty"`
+	// DialContext, if set, opens the network connections instead of the default dialer
+	DialContext   DialContextFunc   `json:"-"`
+	TransportOpts *TransportOptions `json:"transport,omitempty"`
+
+	// Timeout bounds every request whose context has no deadline. Zero uses types.DefaultTimeout and a negative
+	// value disables it. Use WithCallTimeout to set the timeout of a single call.
+	Timeout time.Duration `json:"timeout,omitempty"`
+	// HTTPClient, if set, is used for the requests instead of a new http.Client. It is copied, and its cookie jar
+	// is replaced by the session one. Its transport is kept, in which case TLS, Proxy, DialContext and TransportOpts
+	// are ignored.
+	HTTPClient *http.Client `json:"-"`
+	// Transport, if set, replaces the transport built from TLS, Proxy, DialContext and TransportOpts
+	Transport   http.RoundTripper `json:"-"`
+	Middlewares []Middleware      `json:"-"`
+
+	// CredentialProvider, if set, is consulted for the credentials on every re-authentication
+	CredentialProvider CredentialProvider `json:"-"`
+	// CookieStore, if set, persists the cookies instead of the plain CookieFile
+	CookieStore CookieStore `json:"-"`
+	// TwoFactorHandler, if set, provides the code of logins requiring two-factor authentication
+	TwoFactorHandler TwoFactorHandler `json:"-"`
+	// Tracer, if set, creates a span for every API call and HTTP request and propagates the trace context
+	Tracer Tracer `json:"-"`
+	// Metrics, if set, receives the measures of every request and re-authentication
+	Metrics Metrics `json:"-"`
+	// Logger, if set, receives the logs of the client instead of the default logger. See SetDefaultLogger.
+	Logger Logger `json:"-"`
 }
 
 func DefaultSessionOpts() *SessionOptions {
@@ -23,10 +58,10 @@ func DefaultSessionOpts() *SessionOptions {
 		Insecure:       false,
 		ReAuthenticate: false,
 		PersistCookie:  true,
-		CookieFile:     types.DefaultCookieFile,
 		AuthHeader:     "",
 		Compress:       true,
 		Debug:          false,
+		Timeout:        types.DefaultTimeout * time.Second,
 	}
 }
 
@@ -62,6 +97,22 @@ func WithCookieFile(cookieFile string) SessionOptFunc {
 	}
 }
 
+// WithCookieStore persists the session cookies in the given store, e.g. NewEncryptedFileCookieStore
+func WithCookieStore(store CookieStore) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.PersistCookie = true
+		opts.CookieStore = store
+	}
+}
+
+// WithAuthnInfo makes every request on behalf of info through the nuvla-authn-info header. It is only sent to
+// the Nuvla endpoint.
+func WithAuthnInfo(info *AuthnInfo) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.AuthHeader = info.String()
+	}
+}
+
 func WithAuthHeader(authHeader string) SessionOptFunc {
 	return func(opts *SessionOptions) {
 		opts.AuthHeader = authHeader
@@ -72,6 +123,192 @@ func WithOutCompressSession(opts *SessionOptions) {
 	opts.Compress = false
 }
 
+// WithRetryPolicy enables automatic retries of transient failures. Use DefaultRetryPolicy as a starting point.
+func WithRetryPolicy(policy *RetryPolicy) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.Retry = policy
+	}
+}
+
+// WithMiddleware registers middlewares wrapping every request made through NuvlaSession.Request, login included.
+// Middlewares run in the order they are registered, after the built-in cookie persistence and authentication
+// header middlewares.
+func WithMiddleware(mws ...Middleware) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.Middlewares = append(opts.Middlewares, mws...)
+	}
+}
+
+// WithCredentialProvider sets the provider of the credentials used to log in and re-authenticate
+func WithCredentialProvider(provider CredentialProvider) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.CredentialProvider = provider
+	}
+}
+
+// tlsOptions returns the TLS options, creating them if needed
+func (opts *SessionOptions) tlsOptions() *TLSOptions {
+	if opts.TLS == nil {
+		opts.TLS = &TLSOptions{}
+	}
+	return opts.TLS
+}
+
+// WithTLSOptions replaces the whole TLS configuration
+func WithTLSOptions(tlsOpts *TLSOptions) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.TLS = tlsOpts
+	}
+}
+
+// WithCAFile trusts the certificate authorities of the PEM bundle in caFile, in addition to the system ones
+func WithCAFile(caFile string) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.tlsOptions().CAFile = caFile
+	}
+}
+
+// WithCAPEM trusts the PEM encoded certificate authorities, in addition to the system ones
+func WithCAPEM(caPEM []byte) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.tlsOptions().CAPEM = caPEM
+	}
+}
+
+// WithClientCertificate authenticates the client with the certificate and key files. They are reloaded when they
+// change on disk.
+func WithClientCertificate(certFile, keyFile string) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		t := opts.tlsOptions()
+		t.CertFile = certFile
+		t.KeyFile = keyFile
+	}
+}
+
+// WithClientCertificatePEM authenticates the client with the PEM encoded certificate and key
+func WithClientCertificatePEM(certPEM, keyPEM []byte) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		t := opts.tlsOptions()
+		t.CertPEM = certPEM
+		t.KeyPEM = keyPEM
+	}
+}
+
+// WithMinTLSVersion sets the minimum TLS version, e.g. tls.VersionTLS13
+func WithMinTLSVersion(version uint16) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.tlsOptions().MinVersion = version
+	}
+}
+
+// WithPinnedSPKI only accepts servers presenting a certificate whose public key hash is in spkiHashes.
+// See SPKIHash.
+func WithPinnedSPKI(spkiHashes ...string) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		t := opts.tlsOptions()
+		t.PinnedSPKI = append(t.PinnedSPKI, spkiHashes...)
+	}
+}
+
+// proxyOptions returns the proxy options, creating them if needed
+func (opts *SessionOptions) proxyOptions() *ProxyOptions {
+	if opts.Proxy == nil {
+		opts.Proxy = &ProxyOptions{}
+	}
+	return opts.Proxy
+}
+
+// WithProxy sends the requests through the proxy at proxyURL, e.g. "http://proxy:3128" or "socks5://proxy:1080".
+// The proxy from the environment is used otherwise.
+func WithProxy(proxyURL string) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.proxyOptions().URL = proxyURL
+	}
+}
+
+// WithProxyCredentials authenticates against the proxy with username and password
+func WithProxyCredentials(username, password string) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		p := opts.proxyOptions()
+		p.Username = username
+		p.Password = password
+	}
+}
+
+// WithNoProxy reaches the given hosts directly, bypassing the proxy. See ProxyOptions.NoProxy for the format.
+func WithNoProxy(hosts ...string) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		p := opts.proxyOptions()
+		p.NoProxy = append(p.NoProxy, hosts...)
+	}
+}
+
+// WithDialContext opens the network connections with dial, e.g. UnixSocketDialer or the DialContext of a
+// net.Dialer bound to a VPN interface address
+func WithDialContext(dial DialContextFunc) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.DialContext = dial
+	}
+}
+
+// WithTimeout sets the timeout of the requests whose context has no deadline. A negative timeout disables it.
+func WithTimeout(timeout time.Duration) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.Timeout = timeout
+	}
+}
+
+// WithHTTPClient sends the requests with a copy of client. See SessionOptions.HTTPClient.
+func WithHTTPClient(client *http.Client) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.HTTPClient = client
+	}
+}
+
+// WithTransport sends the requests through transport, e.g. an instrumented http.RoundTripper
+func WithTransport(transport http.RoundTripper) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.Transport = transport
+	}
+}
+
+// WithTransportOptions tunes the connection pool, keep-alive and HTTP/2 settings of the transport
+func WithTransportOptions(transportOpts *TransportOptions) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.TransportOpts = transportOpts
+	}
+}
+
+// WithTwoFactorHandler answers the two-factor authentication challenges of logins and re-authentications with
+// handler, e.g. TerminalTwoFactorPrompt
+func WithTwoFactorHandler(handler TwoFactorHandler) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.TwoFactorHandler = handler
+	}
+}
+
+// WithTracer traces the API calls and HTTP requests with tracer, e.g. otelnuvla.NewTracer for OpenTelemetry
+func WithTracer(tracer Tracer) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.Tracer = tracer
+	}
+}
+
+// WithLogger sends the logs of the client to logger, e.g. slog.Default(). Secrets are redacted from the messages.
+func WithLogger(logger Logger) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.Logger = logger
+	}
+}
+
+// WithMetrics reports the measures of the requests and re-authentications to metrics, e.g. promnuvla.NewCollector
+// for Prometheus
+func WithMetrics(metrics Metrics) SessionOptFunc {
+	return func(opts *SessionOptions) {
+		opts.Metrics = metrics
+	}
+}
+
 func NewSessionOpts(opts *SessionOptions) *SessionOptions {
 	if opts == nil {
 		opts = &SessionOptions{}
@@ -81,10 +318,7 @@ func NewSessionOpts(opts *SessionOptions) *SessionOptions {
 		opts.Endpoint = types.DefaultEndpoint
 	}
 	// Insecure is already false as default since declared boolean variables are initialised to 0
-	// The same applies to ReAuthenticate, persist Cookie and Debug.
-	if opts.PersistCookie && opts.CookieFile == "" {
-		opts.CookieFile = types.DefaultCookieFile
-	}
+	// The same applies to ReAuthenticate, persist Cookie and Debug. An empty CookieFile uses DefaultCookieFile.
 
 	return opts
 }
@@ -1 +1,113 @@
 package api_client_go
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+// gzipJSON returns v encoded as gzip compressed JSON
+func gzipJSON(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(gz).Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestNuvlaSession_UncompressedRequestAndRequestedGzipResponse(t *testing.T) {
+	var contentEncoding string
+	var requestBody map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentEncoding = r.Header.Get("Content-Encoding")
+		_ = json.NewDecoder(r.Body).Decode(&requestBody)
+
+		w.Header().Set("Content-Type", "application/json")
+		resource := map[string]interface{}{"id": "nuvlabox/1", "name": "compressed"}
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			_ = json.NewEncoder(w).Encode(resource)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		_, _ = w.Write(gzipJSON(t, resource))
+	}))
+	defer srv.Close()
+
+	// The default options send the request body as is
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie)
+	res, err := c.Edit(context.Background(), "nuvlabox/1", map[string]interface{}{"name": "compressed"}, nil)
+	if err != nil {
+		t.Fatalf("edit failed: %s", err)
+	}
+	if contentEncoding != "" || requestBody["name"] != "compressed" || res.Data["name"] != "compressed" {
+		t.Errorf("unexpected request %v with encoding %q or response %v", requestBody, contentEncoding, res.Data)
+	}
+
+	// A gzip response asked for explicitly is decompressed by the session
+	resp, err := c.Request(context.Background(), &types.RequestOpts{
+		Method:   http.MethodGet,
+		Endpoint: srv.URL + "/api/nuvlabox/1",
+		Headers:  map[string]string{"Accept-Encoding": "gzip"},
+	})
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	var resource map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&resource); err != nil || resource["name"] != "compressed" {
+		t.Errorf("expected the decompressed resource, got %v, %v", resource, err)
+	}
+}
+
+func TestDecompressResponse(t *testing.T) {
+	compressed := gzipJSON(t, map[string]string{"id": "nuvlabox/1"})
+	newResponse := func(encoding string, body []byte) *http.Response {
+		resp := &http.Response{Header: make(http.Header), Body: io.NopCloser(bytes.NewReader(body)), ContentLength: int64(len(body))}
+		if encoding != "" {
+			resp.Header.Set("Content-Encoding", encoding)
+		}
+		return resp
+	}
+
+	resp := decompressResponse(newResponse("gzip", compressed), true)
+	b, err := io.ReadAll(resp.Body)
+	if err != nil || !strings.Contains(string(b), "nuvlabox/1") {
+		t.Errorf("expected the decompressed body, got %q, error %v", b, err)
+	}
+	if resp.Header.Get("Content-Encoding") != "" || resp.ContentLength != -1 || !resp.Uncompressed {
+		t.Errorf("expected the encoding headers to be removed, got %v", resp.Header)
+	}
+	if n := resp.Body.(*gzipBody).wire.n; n != int64(len(compressed)) {
+		t.Errorf("expected %d compressed bytes, got %d", len(compressed), n)
+	}
+
+	// Left to the transport if the request did not ask for gzip, and never applied to other encodings
+	for _, resp := range []*http.Response{
+		decompressResponse(newResponse("gzip", compressed), false),
+		decompressResponse(newResponse("br", compressed), true),
+		decompressResponse(newResponse("", compressed), true),
+	} {
+		if _, ok := resp.Body.(*gzipBody); ok {
+			t.Errorf("unexpected decompression with encoding %q", resp.Header.Get("Content-Encoding"))
+		}
+	}
+
+	// Empty bodies, e.g. of HEAD requests, are only an error when read
+	resp = decompressResponse(newResponse("gzip", nil), true)
+	if err := resp.Body.Close(); err != nil {
+		t.Errorf("unexpected error closing an empty body: %s", err)
+	}
+}
@@ -0,0 +1,292 @@
+package api_client_go
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/nuvla/api-client-go/common"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSOptions configures the TLS connections to the Nuvla endpoint. Files are read on every handshake if they
+// changed on disk, so rotated certificates are picked up without rebuilding the client.
+type TLSOptions struct {
+	// CAFile is a PEM bundle of the certificate authorities trusted in addition to the system ones
+	CAFile string `json:"ca-file,omitempty"`
+	// CAPEM holds PEM encoded certificate authorities, trusted in addition to the system ones
+	CAPEM []byte `json:"ca-pem,omitempty"`
+
+	// CertFile and KeyFile are the PEM encoded client certificate and private key for mutual TLS
+	CertFile string `json:"cert-file,omitempty"`
+	KeyFile  string `json:"key-file,omitempty"`
+	// CertPEM and KeyPEM are the in-memory alternative to CertFile and KeyFile. They are never serialised.
+	CertPEM []byte `json:"-"`
+	KeyPEM  []byte `json:"-"`
+
+	// MinVersion is the minimum TLS version accepted, e.g. tls.VersionTLS13. Defaults to TLS 1.2.
+	MinVersion uint16 `json:"min-version,omitempty"`
+
+	// PinnedSPKI lists the base64 encoded SHA-256 hashes of the subject public key info of accepted certificates.
+	// If set, the verified server chain must contain at least one of them. With Insecure, only the server
+	// certificate itself is checked.
+	PinnedSPKI []string `json:"pinned-spki,omitempty"`
+}
+
+// SPKIHash returns the base64 encoded SHA-256 hash of the certificate subject public key info, as used in
+// TLSOptions.PinnedSPKI
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// newTLSConfig builds the TLS configuration of the transport. serverName is the host of the endpoint, checked
+// against the server certificate with custom authorities when the connection has no SNI name, e.g. for an IP.
+func newTLSConfig(insecure bool, serverName string, opts *TLSOptions, logger *common.PrintfLogger) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecure,
+	}
+	if opts == nil {
+		return config, nil
+	}
+	if opts.MinVersion != 0 {
+		config.MinVersion = opts.MinVersion
+	}
+
+	var errs []error
+	roots := &caLoader{file: opts.CAFile, pem: opts.CAPEM, log: logger}
+	if roots.configured() {
+		if _, err := roots.pool(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	certs := &certLoader{certFile: opts.CertFile, keyFile: opts.KeyFile, certPEM: opts.CertPEM, keyPEM: opts.KeyPEM, log: logger}
+	if certs.keyFile == "" {
+		// The key can be in the same file as the certificate
+		certs.keyFile = certs.certFile
+	}
+	if certs.configured() {
+		if _, err := certs.certificate(); err != nil {
+			errs = append(errs, err)
+		}
+		config.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return certs.certificate()
+		}
+	}
+
+	pins := make(map[string]bool, len(opts.PinnedSPKI))
+	for _, pin := range opts.PinnedSPKI {
+		pins[pin] = true
+	}
+
+	customRoots := roots.configured() && !insecure
+	if customRoots {
+		// The default verification cannot use a pool which changes over time, so it is done in VerifyConnection
+		config.InsecureSkipVerify = true
+	}
+	if customRoots || len(pins) > 0 {
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			chains := cs.VerifiedChains
+			if customRoots {
+				var err error
+				if chains, err = verifyServerChain(cs, serverName, roots); err != nil {
+					return err
+				}
+			}
+			return verifyPins(cs, chains, insecure, pins)
+		}
+	}
+
+	return config, errors.Join(errs...)
+}
+
+// verifyServerChain verifies the server certificates against the system roots and the configured authorities,
+// and returns the verified chains. The certificate must be issued for the SNI name of the connection or, without
+// SNI, for serverName. It is rejected if neither is known.
+func verifyServerChain(cs tls.ConnectionState, serverName string, roots *caLoader) ([][]*x509.Certificate, error) {
+	if len(cs.PeerCertificates) == 0 {
+		return nil, errors.New("server did not present any certificate")
+	}
+	host := cs.ServerName
+	if host == "" {
+		host = serverName
+	}
+	if host == "" {
+		return nil, errors.New("cannot verify the server certificate without the server name")
+	}
+	pool, err := roots.pool()
+	if err != nil {
+		return nil, err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range cs.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	return cs.PeerCertificates[0].Verify(x509.VerifyOptions{
+		DNSName:       host,
+		Roots:         pool,
+		Intermediates: intermediates,
+	})
+}
+
+// verifyPins checks that one of the certificates of the verified chains matches the pinned public keys, if any.
+// The certificates sent by the server are not trusted as is: any of them could be appended to a chain issued for
+// the host. Without verification, only the leaf certificate is considered.
+func verifyPins(cs tls.ConnectionState, chains [][]*x509.Certificate, insecure bool, pins map[string]bool) error {
+	if len(pins) == 0 {
+		return nil
+	}
+	if insecure && len(cs.PeerCertificates) > 0 {
+		chains = [][]*x509.Certificate{cs.PeerCertificates[:1]}
+	}
+	for _, chain := range chains {
+		for _, cert := range chain {
+			if pins[SPKIHash(cert)] {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("no certificate of %s matches the pinned public keys", cs.ServerName)
+}
+
+// fileVersion identifies the content of a file on disk, to reload it only when it changed
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFileVersion(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+func (v fileVersion) equal(other fileVersion) bool {
+	return v.modTime.Equal(other.modTime) && v.size == other.size
+}
+
+// caLoader builds the pool of trusted authorities, reloading the CA file when it changes
+type caLoader struct {
+	file string
+	pem  []byte
+	log  *common.PrintfLogger
+
+	mu      sync.Mutex
+	version fileVersion
+	cached  *x509.CertPool
+}
+
+func (l *caLoader) configured() bool {
+	return l.file != "" || len(l.pem) > 0
+}
+
+func (l *caLoader) pool() (*x509.CertPool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var version fileVersion
+	if l.file != "" {
+		v, err := statFileVersion(l.file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %w", err)
+		}
+		version = v
+	}
+	if l.cached != nil && version.equal(l.version) {
+		return l.cached, nil
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		l.log.Warnf("Cannot load system certificate authorities: %s", err)
+		pool = x509.NewCertPool()
+	}
+	if len(l.pem) > 0 && !pool.AppendCertsFromPEM(l.pem) {
+		return nil, errors.New("no valid certificate found in CA PEM")
+	}
+	if l.file != "" {
+		b, err := os.ReadFile(l.file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no valid certificate found in CA file %s", l.file)
+		}
+		l.log.Debugf("Loaded certificate authorities from %s", l.file)
+	}
+
+	l.cached = pool
+	l.version = version
+	return pool, nil
+}
+
+// certLoader provides the client certificate, reloading the certificate and key files when they change
+type certLoader struct {
+	certFile string
+	keyFile  string
+	certPEM  []byte
+	keyPEM   []byte
+	log      *common.PrintfLogger
+
+	mu          sync.Mutex
+	certVersion fileVersion
+	keyVersion  fileVersion
+	cached      *tls.Certificate
+}
+
+func (l *certLoader) configured() bool {
+	return l.certFile != "" || len(l.certPEM) > 0
+}
+
+func (l *certLoader) certificate() (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.certFile == "" {
+		if l.cached == nil {
+			cert, err := tls.X509KeyPair(l.certPEM, l.keyPEM)
+			if err != nil {
+				return nil, fmt.Errorf("error loading client certificate: %w", err)
+			}
+			l.cached = &cert
+		}
+		return l.cached, nil
+	}
+
+	certVersion, err := statFileVersion(l.certFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client certificate: %w", err)
+	}
+	keyVersion, err := statFileVersion(l.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client key: %w", err)
+	}
+	if l.cached != nil && certVersion.equal(l.certVersion) && keyVersion.equal(l.keyVersion) {
+		return l.cached, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		// The certificate and the key are not replaced at the same time: keep the previous pair in the meantime
+		if l.cached != nil {
+			l.log.Warnf("Error reloading client certificate, using the previous one: %s", err)
+			return l.cached, nil
+		}
+		return nil, fmt.Errorf("error loading client certificate: %w", err)
+	}
+	l.log.Debugf("Loaded client certificate from %s", l.certFile)
+
+	l.cached = &cert
+	l.certVersion = certVersion
+	l.keyVersion = keyVersion
+	return l.cached, nil
+}
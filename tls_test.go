@@ -0,0 +1,174 @@
+package api_client_go
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"context"
+
+	"github.com/nuvla/api-client-go/common"
+)
+
+const testServerName = "nuvla.test"
+
+// newTestCertificate creates a certificate signed by parent, or self-signed if parent is nil. Leaf certificates are
+// issued for hosts, host names or IP addresses, defaulting to testServerName.
+func newTestCertificate(t *testing.T, cn string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, hosts ...string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if !isCA {
+		if len(hosts) == 0 {
+			hosts = []string{testServerName}
+		}
+		for _, h := range hosts {
+			if ip := net.ParseIP(h); ip != nil {
+				tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+			} else {
+				tmpl.DNSNames = append(tmpl.DNSNames, h)
+			}
+		}
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestNewTLSConfig_PinnedSPKI(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Test CA", true, nil, nil)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	pinnedLeaf, _ := newTestCertificate(t, testServerName, false, ca, caKey)
+	otherLeaf, _ := newTestCertificate(t, testServerName, false, ca, caKey)
+	// A certificate issued by the CA for the host, with the pinned certificate appended to its chain
+	forgedChain := []*x509.Certificate{otherLeaf, pinnedLeaf}
+
+	tests := []struct {
+		name     string
+		insecure bool
+		caPEM    []byte
+		peers    []*x509.Certificate
+		verified [][]*x509.Certificate
+		wantErr  bool
+	}{
+		{name: "pinned leaf with custom CA", caPEM: caPEM, peers: []*x509.Certificate{pinnedLeaf}},
+		{name: "pinned certificate appended with custom CA", caPEM: caPEM, peers: forgedChain, wantErr: true},
+		{name: "other leaf with custom CA", caPEM: caPEM, peers: []*x509.Certificate{otherLeaf}, wantErr: true},
+		{name: "pinned leaf with default verification", peers: []*x509.Certificate{pinnedLeaf},
+			verified: [][]*x509.Certificate{{pinnedLeaf, ca}}},
+		{name: "pinned certificate appended with default verification", peers: forgedChain,
+			verified: [][]*x509.Certificate{{otherLeaf, ca}}, wantErr: true},
+		{name: "pinned leaf without verification", insecure: true, peers: []*x509.Certificate{pinnedLeaf}},
+		{name: "pinned certificate appended without verification", insecure: true, peers: forgedChain, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &TLSOptions{CAPEM: tt.caPEM, PinnedSPKI: []string{SPKIHash(pinnedLeaf)}}
+			config, err := newTLSConfig(tt.insecure, testServerName, opts, common.DefaultLogger())
+			if err != nil {
+				t.Fatalf("unexpected configuration error: %s", err)
+			}
+			err = config.VerifyConnection(tls.ConnectionState{
+				ServerName:       testServerName,
+				PeerCertificates: tt.peers,
+				VerifiedChains:   tt.verified,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_PinnedCA(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Test CA", true, nil, nil)
+	leaf, _ := newTestCertificate(t, testServerName, false, ca, caKey)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+
+	config, err := newTLSConfig(false, testServerName, &TLSOptions{CAPEM: caPEM, PinnedSPKI: []string{SPKIHash(ca)}}, common.DefaultLogger())
+	if err != nil {
+		t.Fatalf("unexpected configuration error: %s", err)
+	}
+	// The CA is part of the verified chain even if the server does not send it
+	err = config.VerifyConnection(tls.ConnectionState{ServerName: testServerName, PeerCertificates: []*x509.Certificate{leaf}})
+	if err != nil {
+		t.Errorf("expected the pinned CA to match, got %s", err)
+	}
+}
+
+func TestNewTLSConfig_CustomCAWithIPEndpoint(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Test CA", true, nil, nil)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+
+	tests := []struct {
+		name    string
+		hosts   []string
+		wantErr bool
+	}{
+		{name: "certificate for the IP", hosts: []string{"127.0.0.1"}},
+		{name: "certificate for another name", hosts: []string{testServerName}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			leaf, key := newTestCertificate(t, tt.hosts[0], false, ca, caKey, tt.hosts...)
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				_, _ = w.Write([]byte(`{"id": "cloud-entry-point"}`))
+			}))
+			srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{leaf.Raw}, PrivateKey: key}}}
+			srv.StartTLS()
+			defer srv.Close()
+
+			// No SNI is sent to an IP endpoint: the certificate is checked against the endpoint host
+			c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie, WithCAPEM(caPEM))
+			_, err := c.Get(context.Background(), "cloud-entry-point", nil)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("expected error: %t, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewTLSConfig_CustomCAWithoutServerName(t *testing.T) {
+	ca, caKey := newTestCertificate(t, "Test CA", true, nil, nil)
+	leaf, _ := newTestCertificate(t, testServerName, false, ca, caKey)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+
+	config, err := newTLSConfig(false, "", &TLSOptions{CAPEM: caPEM}, common.DefaultLogger())
+	if err != nil {
+		t.Fatalf("unexpected configuration error: %s", err)
+	}
+	if err := config.VerifyConnection(tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}); err == nil {
+		t.Error("expected the certificate to be rejected without server name")
+	}
+}
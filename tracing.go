@@ -0,0 +1,195 @@
+package api_client_go
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+// Attribute keys of the spans created by the client
+const (
+	AttrHTTPMethod      = "http.request.method"
+	AttrHTTPStatusCode  = "http.response.status_code"
+	AttrURL             = "url.full"
+	AttrServerAddress   = "server.address"
+	AttrResourceType    = "nuvla.resource.type"
+	AttrResourceId      = "nuvla.resource.id"
+	AttrOperation       = "nuvla.operation"
+	AttrBulk            = "nuvla.bulk"
+	AttrReAuthenticated = "nuvla.reauthenticated"
+)
+
+// SpanKind tells whether a span covers a whole API call or a single HTTP request
+type SpanKind int
+
+const (
+	// SpanKindInternal spans cover an API call, retries and re-authentication included
+	SpanKindInternal SpanKind = iota
+	// SpanKindClient spans cover a single HTTP request sent to the server
+	SpanKindClient
+)
+
+// Attribute is a key-value pair attached to a span. Values are strings, ints or bools.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Span is a unit of work started by a Tracer
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	// RecordError marks the span as failed
+	RecordError(err error)
+	End()
+}
+
+// Tracer creates the spans of the client. It is the extension point for tracing libraries, see the otelnuvla
+// package for OpenTelemetry. Without a Tracer, no span is created.
+type Tracer interface {
+	// Start starts a span, child of the span in ctx if any, and returns the context holding it
+	Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, Span)
+	// Inject writes the trace context of ctx in the outgoing request headers, e.g. the W3C traceparent header
+	Inject(ctx context.Context, header http.Header)
+}
+
+// callTarget describes the target of an API call, as parsed from the request endpoint
+type callTarget struct {
+	resourceType string
+	resourceId   string
+	operation    string
+}
+
+// newCallTarget parses the resource and operation of a request to <endpoint>/api/<resource-type>[/<uuid>[/<op>]].
+// CRUD requests are named after the CIMI actions: add, get, edit, delete and search.
+func newCallTarget(reqInput *types.RequestOpts) callTarget {
+	var t callTarget
+	u, err := url.Parse(reqInput.Endpoint)
+	if err != nil {
+		return t
+	}
+	_, path, found := strings.Cut(u.Path, "/api/")
+	if !found {
+		return t
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	t.resourceType = parts[0]
+	switch {
+	case len(parts) == 2 && reqInput.Bulk:
+		// Bulk operations apply to the collection
+		t.operation = parts[1]
+		return t
+	case len(parts) >= 2:
+		if id := types.NewNuvlaIDFromId(parts[0] + "/" + parts[1]); id != nil {
+			t.resourceType = id.ResourceType
+			t.resourceId = id.Id
+		}
+	}
+	if len(parts) >= 3 {
+		t.operation = parts[2]
+		return t
+	}
+
+	collection := len(parts) == 1
+	switch reqInput.Method {
+	case http.MethodGet:
+		t.operation = "get"
+		if collection {
+			t.operation = "search"
+		}
+	case http.MethodPut:
+		t.operation = "edit"
+		if collection {
+			t.operation = "search"
+		}
+	case http.MethodPost:
+		t.operation = "add"
+	case http.MethodDelete:
+		t.operation = "delete"
+	}
+	return t
+}
+
+func (t callTarget) spanName() string {
+	name := "nuvla"
+	if t.operation != "" {
+		name += " " + t.operation
+	}
+	if t.resourceType != "" {
+		name += " " + t.resourceType
+	}
+	return name
+}
+
+func (t callTarget) attributes(reqInput *types.RequestOpts) []Attribute {
+	attrs := []Attribute{
+		{Key: AttrHTTPMethod, Value: reqInput.Method},
+		{Key: AttrResourceType, Value: t.resourceType},
+		{Key: AttrOperation, Value: t.operation},
+		{Key: AttrBulk, Value: reqInput.Bulk},
+		{Key: AttrReAuthenticated, Value: false},
+	}
+	if t.resourceId != "" {
+		attrs = append(attrs, Attribute{Key: AttrResourceId, Value: t.resourceId})
+	}
+	return attrs
+}
+
+// noopSpan is used when no Tracer is configured
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
+
+// startCallSpan starts the span of an API call
+func (s *NuvlaSession) startCallSpan(ctx context.Context, reqInput *types.RequestOpts) (context.Context, Span) {
+	if s.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	t := newCallTarget(reqInput)
+	return s.tracer.Start(ctx, t.spanName(), SpanKindInternal, t.attributes(reqInput)...)
+}
+
+// startRequestSpan starts the span of a single HTTP request and propagates its trace context in the headers
+func (s *NuvlaSession) startRequestSpan(req *http.Request) (*http.Request, Span) {
+	if s.tracer == nil {
+		return req, noopSpan{}
+	}
+	ctx, span := s.tracer.Start(req.Context(), "HTTP "+req.Method, SpanKindClient,
+		Attribute{Key: AttrHTTPMethod, Value: req.Method},
+		Attribute{Key: AttrURL, Value: redactedURL(req.URL)},
+		Attribute{Key: AttrServerAddress, Value: req.URL.Hostname()},
+	)
+	s.tracer.Inject(ctx, req.Header)
+	return req.WithContext(ctx), span
+}
+
+// endSpan records the outcome of a request or a call on span and ends it
+func endSpan(span Span, resp *http.Response, err error) {
+	var apiErr *types.NuvlaAPIError
+	switch {
+	case resp != nil:
+		span.SetAttributes(Attribute{Key: AttrHTTPStatusCode, Value: resp.StatusCode})
+	case errors.As(err, &apiErr):
+		span.SetAttributes(Attribute{Key: AttrHTTPStatusCode, Value: apiErr.StatusCode})
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+// redactedURL returns u without user information, which must not end up in traces
+func redactedURL(u *url.URL) string {
+	if u.User == nil {
+		return u.String()
+	}
+	c := *u
+	c.User = nil
+	return c.String()
+}
@@ -0,0 +1,168 @@
+package api_client_go
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+func TestNewCallTarget(t *testing.T) {
+	tests := []struct {
+		method   string
+		path     string
+		bulk     bool
+		expected callTarget
+	}{
+		{"GET", "nuvlabox/1", false, callTarget{resourceType: "nuvlabox", resourceId: "nuvlabox/1", operation: "get"}},
+		{"PUT", "nuvlabox", false, callTarget{resourceType: "nuvlabox", operation: "search"}},
+		{"PUT", "nuvlabox/1", false, callTarget{resourceType: "nuvlabox", resourceId: "nuvlabox/1", operation: "edit"}},
+		{"POST", "deployment", false, callTarget{resourceType: "deployment", operation: "add"}},
+		{"DELETE", "job/2", false, callTarget{resourceType: "job", resourceId: "job/2", operation: "delete"}},
+		{"POST", "nuvlabox/1/heartbeat", false, callTarget{resourceType: "nuvlabox", resourceId: "nuvlabox/1", operation: "heartbeat"}},
+		{"POST", "deployment/bulk-stop", true, callTarget{resourceType: "deployment", operation: "bulk-stop"}},
+	}
+	for _, tt := range tests {
+		got := newCallTarget(&types.RequestOpts{Method: tt.method, Endpoint: "https://nuvla.io/api/" + tt.path, Bulk: tt.bulk})
+		if got != tt.expected {
+			t.Errorf("%s %s: expected %+v, got %+v", tt.method, tt.path, tt.expected, got)
+		}
+	}
+}
+
+const testTraceHeader = "X-Test-Span"
+
+// fakeSpan is a span of fakeTracer
+type fakeSpan struct {
+	id     int
+	parent int
+	name   string
+	kind   SpanKind
+	attrs  map[string]interface{}
+	ended  bool
+}
+
+func (s *fakeSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *fakeSpan) RecordError(error) {}
+
+func (s *fakeSpan) End() {
+	s.ended = true
+}
+
+type fakeSpanKey struct{}
+
+// fakeTracer records the spans started and injects the id of the current span in testTraceHeader
+type fakeTracer struct {
+	mu      sync.Mutex
+	spans   []*fakeSpan
+	injects int
+}
+
+func (t *fakeTracer) Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	span := &fakeSpan{id: len(t.spans) + 1, name: name, kind: kind, attrs: make(map[string]interface{})}
+	if parent, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+		span.parent = parent.id
+	}
+	span.SetAttributes(attrs...)
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func (t *fakeTracer) Inject(ctx context.Context, header http.Header) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.injects++
+	if span, ok := ctx.Value(fakeSpanKey{}).(*fakeSpan); ok {
+		header.Set(testTraceHeader, strconv.Itoa(span.id))
+	}
+}
+
+func TestNuvlaClient_TracePropagation(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	var mu sync.Mutex
+	var received []string
+	var unavailable atomic.Int32
+	// The proxy answers the first request with 503, then forwards to the test server
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Method+" "+r.URL.Path+" "+r.Header.Get(testTraceHeader))
+		mu.Unlock()
+		if unavailable.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		s.handle(w, r)
+	}))
+	defer proxy.Close()
+
+	tracer := &fakeTracer{}
+	policy := &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, RetryableStatusCodes: []int{http.StatusServiceUnavailable}}
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(proxy.URL), WithoutPersistCookie, ReAuthenticateSession,
+		WithRetryPolicy(policy), WithTracer(tracer))
+	if err := c.LoginApiKeys(context.Background(), "credential/key", "secret"); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+
+	// The request is retried on 503, rejected with 401, re-authenticated and sent again
+	mu.Lock()
+	received = nil
+	mu.Unlock()
+	tracer.mu.Lock()
+	tracer.spans, tracer.injects = nil, 0
+	tracer.mu.Unlock()
+	s.expireSession()
+	unavailable.Store(1)
+
+	ctx, parent := tracer.Start(context.Background(), "test", SpanKindInternal)
+	if _, err := c.Get(ctx, "nuvlabox/1", nil); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	parent.End()
+
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	// test, the call span, then one client span per HTTP request
+	if len(tracer.spans) != 6 {
+		t.Fatalf("expected 6 spans, got %d", len(tracer.spans))
+	}
+	call := tracer.spans[1]
+	if call.name != "nuvla get nuvlabox" || call.kind != SpanKindInternal || call.parent != tracer.spans[0].id {
+		t.Errorf("expected the call span under the parent span, got %+v", call)
+	}
+	if call.attrs[AttrReAuthenticated] != true || call.attrs[AttrHTTPStatusCode] != http.StatusOK {
+		t.Errorf("expected a re-authenticated successful call, got %v", call.attrs)
+	}
+
+	wantRequests := []string{"GET /api/nuvlabox/1", "GET /api/nuvlabox/1", "POST /api/session", "GET /api/nuvlabox/1"}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != len(wantRequests) {
+		t.Fatalf("expected requests %v, got %v", wantRequests, received)
+	}
+	for i, span := range tracer.spans[2:] {
+		if span.kind != SpanKindClient || span.parent != call.id || !span.ended {
+			t.Errorf("expected an ended client span under the call span, got %+v", span)
+		}
+		// The server receives the trace context of the span of each request
+		if want := fmt.Sprintf("%s %d", wantRequests[i], span.id); received[i] != want {
+			t.Errorf("request %d: expected %q, got %q", i, want, received[i])
+		}
+	}
+	if tracer.injects != len(wantRequests) {
+		t.Errorf("expected %d injections, got %d", len(wantRequests), tracer.injects)
+	}
+}
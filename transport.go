@@ -0,0 +1,169 @@
+package api_client_go
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"github.com/nuvla/api-client-go/common"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// TransportOptions tune the connection pool and protocol settings of the transport built by the client.
+// Zero values keep the http.DefaultTransport defaults.
+type TransportOptions struct {
+	MaxIdleConns        int           `json:"max-idle-conns,omitempty"`
+	MaxIdleConnsPerHost int           `json:"max-idle-conns-per-host,omitempty"`
+	MaxConnsPerHost     int           `json:"max-conns-per-host,omitempty"`
+	IdleConnTimeout     time.Duration `json:"idle-conn-timeout,omitempty"`
+	// KeepAlive is the TCP keep-alive period. A negative value disables TCP keep-alives.
+	// It is ignored if a custom DialContext is set.
+	KeepAlive time.Duration `json:"keep-alive,omitempty"`
+	// DisableKeepAlives opens a new connection for every request
+	DisableKeepAlives     bool          `json:"disable-keep-alives,omitempty"`
+	DisableHTTP2          bool          `json:"disable-http2,omitempty"`
+	TLSHandshakeTimeout   time.Duration `json:"tls-handshake-timeout,omitempty"`
+	ResponseHeaderTimeout time.Duration `json:"response-header-timeout,omitempty"`
+}
+
+// defaultDialTimeout is the connection timeout of http.DefaultTransport
+const defaultDialTimeout = 30 * time.Second
+
+// newHTTPClient builds the http.Client of the session. A custom HTTPClient is copied, so the cookie jar can be set
+// without modifying the caller's client. A custom Transport replaces the one built from the TLS, proxy and
+// transport options.
+func newHTTPClient(opts *SessionOptions, logger *common.PrintfLogger) (*http.Client, error) {
+	client := &http.Client{}
+	if opts.HTTPClient != nil {
+		*client = *opts.HTTPClient
+	}
+
+	var err error
+	switch {
+	case opts.Transport != nil:
+		client.Transport = opts.Transport
+	case opts.HTTPClient != nil && opts.HTTPClient.Transport != nil:
+		// Keep the caller's transport
+	default:
+		client.Transport, err = newTransport(opts, logger)
+	}
+	return client, err
+}
+
+// newBaseTransport clones http.DefaultTransport, or builds a transport with the same settings if the application
+// replaced it with another http.RoundTripper, e.g. an instrumented one
+func newBaseTransport() *http.Transport {
+	if t, ok := http.DefaultTransport.(*http.Transport); ok {
+		return t.Clone()
+	}
+	dialer := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newTransport clones http.DefaultTransport and applies the TLS, proxy, dialer and transport options on top of it
+func newTransport(opts *SessionOptions, logger *common.PrintfLogger) (*http.Transport, error) {
+	t := newBaseTransport()
+
+	proxy, proxyErr := proxyFunc(opts.Proxy)
+	if proxyErr != nil {
+		// Never fall back to a direct connection: fail every request instead
+		proxy = func(*http.Request) (*url.URL, error) {
+			return nil, proxyErr
+		}
+	}
+	t.Proxy = proxy
+
+	if to := opts.TransportOpts; to != nil {
+		applyTransportOptions(t, to)
+	}
+	if opts.DialContext != nil {
+		t.DialContext = opts.DialContext
+	}
+
+	config, tlsErr := newTLSConfig(opts.Insecure, endpointHostname(opts.Endpoint), opts.TLS, logger)
+	// The configuration is usable even if some files could not be loaded: they are loaded again on every handshake
+	t.TLSClientConfig = config
+	return t, errors.Join(proxyErr, tlsErr)
+}
+
+// endpointHostname returns the host name of the endpoint URL without port, or "" if it cannot be parsed
+func endpointHostname(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func applyTransportOptions(t *http.Transport, opts *TransportOptions) {
+	if opts.MaxIdleConns != 0 {
+		t.MaxIdleConns = opts.MaxIdleConns
+	}
+	if opts.MaxIdleConnsPerHost != 0 {
+		t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	}
+	if opts.MaxConnsPerHost != 0 {
+		t.MaxConnsPerHost = opts.MaxConnsPerHost
+	}
+	if opts.IdleConnTimeout != 0 {
+		t.IdleConnTimeout = opts.IdleConnTimeout
+	}
+	if opts.TLSHandshakeTimeout != 0 {
+		t.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	}
+	if opts.ResponseHeaderTimeout != 0 {
+		t.ResponseHeaderTimeout = opts.ResponseHeaderTimeout
+	}
+	if opts.KeepAlive != 0 {
+		d := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: opts.KeepAlive}
+		t.DialContext = d.DialContext
+	}
+	t.DisableKeepAlives = opts.DisableKeepAlives
+	if opts.DisableHTTP2 {
+		t.ForceAttemptHTTP2 = false
+		// A non-nil empty map disables the automatic HTTP/2 upgrade
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+}
+
+// withTimeout derives a context bounded by timeout, unless timeout is not positive
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// cancelOnClose releases the context of a request once its response body is closed, since the body is read after
+// the request returns
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// bindCancel ties cancel to the response body, or calls it straight away if there is no response
+func bindCancel(resp *http.Response, cancel context.CancelFunc) *http.Response {
+	if resp == nil || resp.Body == nil {
+		cancel()
+		return resp
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp
+}
@@ -0,0 +1,88 @@
+package api_client_go
+
+import (
+	"context"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nuvla/api-client-go/common"
+)
+
+type wrappedRoundTripper struct {
+	http.RoundTripper
+}
+
+func TestNewTransport_ReplacedDefaultTransport(t *testing.T) {
+	defaultTransport := http.DefaultTransport
+	http.DefaultTransport = wrappedRoundTripper{RoundTripper: defaultTransport}
+	defer func() { http.DefaultTransport = defaultTransport }()
+
+	transport, err := newTransport(&SessionOptions{}, common.DefaultLogger())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if transport.DialContext == nil || transport.TLSClientConfig == nil || transport.MaxIdleConns == 0 {
+		t.Errorf("expected the default settings, got %+v", transport)
+	}
+}
+
+// countingRoundTripper counts the requests it sends through http.DefaultTransport
+type countingRoundTripper struct {
+	requests atomic.Int32
+}
+
+func (rt *countingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestNewHTTPClient_CustomClient(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	rt := &countingRoundTripper{}
+	callerJar, _ := cookiejar.New(nil)
+	client := &http.Client{Transport: rt, Jar: callerJar}
+
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithoutPersistCookie, WithHTTPClient(client))
+	ctx := context.Background()
+	if err := c.LoginApiKeys(ctx, "credential/key", "secret"); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	if _, err := c.Get(ctx, "nuvlabox/1", nil); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+
+	if n := rt.requests.Load(); n != 2 {
+		t.Errorf("expected the login and the request through the custom transport, got %d requests", n)
+	}
+	if client.Transport != rt || client.Jar != callerJar || client.CheckRedirect != nil {
+		t.Errorf("expected the caller's client to be unchanged, got %+v", client)
+	}
+	endpoint, _ := url.Parse(s.URL)
+	if cookies := callerJar.Cookies(endpoint); len(cookies) != 0 {
+		t.Errorf("expected the session cookies to stay out of the caller's jar, got %v", cookies)
+	}
+}
+
+func TestNewHTTPClient_CustomTransport(t *testing.T) {
+	s := newTestNuvlaServer(t)
+	rt := &countingRoundTripper{}
+	client := &http.Client{}
+
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(s.URL), WithoutPersistCookie, WithHTTPClient(client), WithTransport(rt))
+	if err := c.LoginApiKeys(context.Background(), "credential/key", "secret"); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	// The session cookie is kept in the session jar, so the request is authenticated
+	if _, err := c.Get(context.Background(), "nuvlabox/1", nil); err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	if n := rt.requests.Load(); n != 2 {
+		t.Errorf("expected 2 requests through the transport, got %d", n)
+	}
+	if client.Transport != nil {
+		t.Errorf("expected the caller's client to be unchanged, got transport %v", client.Transport)
+	}
+}
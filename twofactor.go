@@ -0,0 +1,121 @@
+package api_client_go
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/nuvla/api-client-go/types"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// TwoFactorChallenge is the error returned by the login of an account with two-factor authentication. The login
+// is completed by NuvlaClient.CompleteTwoFactor with the code sent by email or generated by the TOTP application.
+type TwoFactorChallenge struct {
+	// CallbackId is the callback resource to execute with the code, e.g. "callback/<uuid>"
+	CallbackId string
+	// Message is the server message, e.g. telling where the code was sent
+	Message string
+
+	loginParams types.LogInParams
+}
+
+func (c *TwoFactorChallenge) Error() string {
+	return fmt.Sprintf("two-factor authentication required: %s", c.Message)
+}
+
+// newTwoFactorChallenge returns the challenge of a login response pointing to a callback, or nil if the login
+// response is a session
+func newTwoFactorChallenge(res *types.NuvlaResponse, loginParams types.LogInParams) *TwoFactorChallenge {
+	callbackId := res.Location
+	if !strings.HasPrefix(callbackId, "callback/") {
+		callbackId = res.ResourceId
+	}
+	if !strings.HasPrefix(callbackId, "callback/") {
+		return nil
+	}
+	return &TwoFactorChallenge{
+		CallbackId:  callbackId,
+		Message:     res.Message,
+		loginParams: loginParams,
+	}
+}
+
+// TwoFactorHandler returns the code answering a two-factor authentication challenge
+type TwoFactorHandler func(ctx context.Context, challenge *TwoFactorChallenge) (string, error)
+
+// PromptTwoFactorCode returns a TwoFactorHandler writing the challenge message to out and reading the code from
+// the next line of in
+func PromptTwoFactorCode(in io.Reader, out io.Writer) TwoFactorHandler {
+	reader := bufio.NewReader(in)
+	return func(_ context.Context, challenge *TwoFactorChallenge) (string, error) {
+		if _, err := fmt.Fprintf(out, "%s\nAuthentication code: ", challenge.Message); err != nil {
+			return "", err
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+		code := strings.TrimSpace(line)
+		if code == "" {
+			return "", errors.New("no authentication code provided")
+		}
+		return code, nil
+	}
+}
+
+// TerminalTwoFactorPrompt asks for the two-factor authentication code on the terminal
+func TerminalTwoFactorPrompt() TwoFactorHandler {
+	return PromptTwoFactorCode(os.Stdin, os.Stderr)
+}
+
+// CompleteTwoFactor completes a login requiring two-factor authentication with the code. The new session is
+// stored in the cookie jar, as for any other login.
+func (nc *NuvlaClient) CompleteTwoFactor(ctx context.Context, challenge *TwoFactorChallenge, code string) error {
+	if challenge == nil || challenge.CallbackId == "" {
+		return errors.New("invalid two-factor authentication challenge")
+	}
+
+	nc.log.Debugf("Completing two-factor authentication with %s", challenge.CallbackId)
+	res, err := nc.Request(ctx, &types.RequestOpts{
+		Method:   http.MethodPost,
+		Endpoint: nc.buildUriEndPoint(nc.buildOperationUriEndPoint(challenge.CallbackId, "execute")),
+		JsonData: map[string]interface{}{"token": code},
+		Headers: map[string]string{
+			"Accept": "application/json",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error completing two-factor authentication: %w", err)
+	}
+	defer func() {
+		_ = res.Body.Close()
+	}()
+
+	if !types.IsSuccessStatusCode(res.StatusCode) {
+		return types.NewNuvlaAPIErrorFromResponse(res)
+	}
+
+	if err := nc.saveCookies(); err != nil {
+		nc.log.Warnf("Error saving cookies after two-factor authentication: %s", err)
+	}
+	nc.loggedIn(challenge.loginParams)
+	return nil
+}
+
+// completeTwoFactorWithHandler answers the challenge with the configured TwoFactorHandler, if any
+func (nc *NuvlaClient) completeTwoFactorWithHandler(ctx context.Context, challenge *TwoFactorChallenge) error {
+	handler := nc.SessionOpts.TwoFactorHandler
+	if handler == nil {
+		return challenge
+	}
+
+	code, err := handler(ctx, challenge)
+	if err != nil {
+		return fmt.Errorf("error getting two-factor authentication code: %w", err)
+	}
+	return nc.CompleteTwoFactor(ctx, challenge, code)
+}
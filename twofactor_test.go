@@ -0,0 +1,176 @@
+package api_client_go
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nuvla/api-client-go/types"
+)
+
+const (
+	testCallbackId = "callback/2fa"
+	testTwoFactor  = "123456"
+)
+
+// newTwoFactorServer returns a Nuvla API answering logins with a callback, executed with testTwoFactor to
+// create the session
+func newTwoFactorServer(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	var executions atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == types.SessionEndpoint:
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"status":   201,
+				"message":  "code sent to j***@example.com",
+				"location": testCallbackId,
+			})
+
+		case r.Method == http.MethodPost && r.URL.Path == "/api/"+testCallbackId+"/execute":
+			executions.Add(1)
+			var body map[string]string
+			if err := decodeRequestBody(r, &body); err != nil || body["token"] != testTwoFactor {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 400, "message": "invalid code"})
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: testSessionCookie, Value: "2fa-token", Path: "/", Expires: time.Now().Add(time.Hour)})
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "resource-id": "session/1"})
+
+		default:
+			if c, err := r.Cookie(testSessionCookie); err != nil || c.Value != "2fa-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": 401, "message": "invalid session"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": strings.TrimPrefix(r.URL.Path, "/api/")})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &executions
+}
+
+// decodeRequestBody decodes the JSON request body, compressed or not
+func decodeRequestBody(r *http.Request, v interface{}) error {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		body = gz
+	}
+	return json.NewDecoder(body).Decode(v)
+}
+
+func TestNuvlaClient_LoginTwoFactorHandler(t *testing.T) {
+	srv, executions := newTwoFactorServer(t)
+
+	var challenges []*TwoFactorChallenge
+	handler := func(_ context.Context, challenge *TwoFactorChallenge) (string, error) {
+		challenges = append(challenges, challenge)
+		return testTwoFactor, nil
+	}
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie, WithTwoFactorHandler(handler))
+	ctx := context.Background()
+
+	if err := c.LoginUser(ctx, "jane", "password"); err != nil {
+		t.Fatalf("login failed: %s", err)
+	}
+	if len(challenges) != 1 || challenges[0].CallbackId != testCallbackId || challenges[0].Message != "code sent to j***@example.com" {
+		t.Errorf("expected one challenge for %s, got %+v", testCallbackId, challenges)
+	}
+	if n := executions.Load(); n != 1 {
+		t.Errorf("expected 1 callback execution, got %d", n)
+	}
+	if c.CurrentCredentials() == nil {
+		t.Error("expected the credentials to be kept after the two-factor login")
+	}
+	if _, err := c.Get(ctx, "nuvlabox/1", nil); err != nil {
+		t.Errorf("request with the two-factor session failed: %s", err)
+	}
+}
+
+func TestNuvlaClient_LoginTwoFactorChallenge(t *testing.T) {
+	srv, _ := newTwoFactorServer(t)
+	c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie)
+	ctx := context.Background()
+
+	err := c.LoginUser(ctx, "jane", "password")
+	var challenge *TwoFactorChallenge
+	if !errors.As(err, &challenge) {
+		t.Fatalf("expected a two-factor challenge without handler, got %v", err)
+	}
+	if challenge.CallbackId != testCallbackId {
+		t.Errorf("expected callback %s, got %s", testCallbackId, challenge.CallbackId)
+	}
+	if c.CurrentCredentials() != nil {
+		t.Error("expected no credentials before the challenge is completed")
+	}
+
+	if err := c.CompleteTwoFactor(ctx, challenge, testTwoFactor); err != nil {
+		t.Fatalf("completing the challenge failed: %s", err)
+	}
+	if c.CurrentCredentials() == nil {
+		t.Error("expected the credentials of the challenge after completion")
+	}
+}
+
+func TestNuvlaClient_LoginTwoFactorErrors(t *testing.T) {
+	handlerErr := errors.New("prompt closed")
+	tests := []struct {
+		name    string
+		handler TwoFactorHandler
+		check   func(t *testing.T, err error)
+	}{
+		{
+			name: "handler error",
+			handler: func(context.Context, *TwoFactorChallenge) (string, error) {
+				return "", handlerErr
+			},
+			check: func(t *testing.T, err error) {
+				if !errors.Is(err, handlerErr) {
+					t.Errorf("expected the handler error, got %v", err)
+				}
+			},
+		},
+		{
+			name: "invalid code",
+			handler: func(context.Context, *TwoFactorChallenge) (string, error) {
+				return "000000", nil
+			},
+			check: func(t *testing.T, err error) {
+				var apiErr *types.NuvlaAPIError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+					t.Errorf("expected a 400 API error, got %v", err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newTwoFactorServer(t)
+			c := NewNuvlaClientFromOpts(nil, WithEndpoint(srv.URL), WithoutPersistCookie, WithTwoFactorHandler(tt.handler))
+
+			err := c.LoginUser(context.Background(), "jane", "password")
+			if err == nil {
+				t.Fatal("expected the login to fail")
+			}
+			tt.check(t, err)
+			if c.CurrentCredentials() != nil {
+				t.Error("expected no credentials after a failed two-factor login")
+			}
+		})
+	}
+}
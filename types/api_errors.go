@@ -0,0 +1,93 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Sentinel errors matching the most common Nuvla API error responses. They can be checked with errors.Is against
+// any error returned by the clients.
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+)
+
+// NuvlaAPIError is the error returned when the Nuvla API answers with an error status code.
+// It is built from the JSON error document sent by the server.
+type NuvlaAPIError struct {
+	// HTTP status code of the response
+	StatusCode int `json:"-"`
+	// Nuvla error document fields
+	Status     int    `json:"status"`
+	Message    string `json:"message"`
+	ResourceId string `json:"resource-id"`
+
+	// Request that caused the error
+	Method string `json:"-"`
+	URL    string `json:"-"`
+}
+
+func (e *NuvlaAPIError) Error() string {
+	msg := fmt.Sprintf("nuvla api error [%s %s]: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.ResourceId != "" {
+		msg += " (" + e.ResourceId + ")"
+	}
+	return msg
+}
+
+// Is maps the HTTP status code of the error to the package sentinel errors
+func (e *NuvlaAPIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	default:
+		return false
+	}
+}
+
+// NewNuvlaAPIErrorFromResponse builds a NuvlaAPIError from an error response. It consumes and closes the response
+// body. If the body is not a Nuvla error document, the message falls back to the raw body.
+func NewNuvlaAPIErrorFromResponse(resp *http.Response) *NuvlaAPIError {
+	apiErr := &NuvlaAPIError{
+		StatusCode: resp.StatusCode,
+	}
+	if resp.Request != nil {
+		apiErr.Method = resp.Request.Method
+		apiErr.URL = resp.Request.URL.String()
+	}
+	if resp.Body == nil {
+		return apiErr
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil || len(body) == 0 {
+		return apiErr
+	}
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		apiErr.Message = string(body)
+	}
+	return apiErr
+}
+
+// IsSuccessStatusCode returns true for 2xx status codes
+func IsSuccessStatusCode(statusCode int) bool {
+	return statusCode >= http.StatusOK && statusCode < http.StatusMultipleChoices
+}
@@ -0,0 +1,104 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newErrorResponse(statusCode int, body string) *http.Response {
+	u, _ := url.Parse("https://nuvla.io/api/nuvlabox/1")
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    &http.Request{Method: http.MethodGet, URL: u},
+	}
+}
+
+func TestNewNuvlaAPIErrorFromResponse_Sentinels(t *testing.T) {
+	sentinels := []error{ErrBadRequest, ErrUnauthorized, ErrForbidden, ErrNotFound, ErrConflict}
+	tests := []struct {
+		statusCode int
+		want       error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusInternalServerError, nil},
+		{http.StatusServiceUnavailable, nil},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			err := error(NewNuvlaAPIErrorFromResponse(newErrorResponse(tt.statusCode, `{"status": 0, "message": "failed"}`)))
+			wrapped := fmt.Errorf("error getting nuvlabox/1: %w", err)
+
+			for _, sentinel := range sentinels {
+				want := sentinel == tt.want
+				if got := errors.Is(err, sentinel); got != want {
+					t.Errorf("errors.Is(%d, %v) = %t, want %t", tt.statusCode, sentinel, got, want)
+				}
+				if got := errors.Is(wrapped, sentinel); got != want {
+					t.Errorf("errors.Is(wrapped %d, %v) = %t, want %t", tt.statusCode, sentinel, got, want)
+				}
+			}
+
+			var apiErr *NuvlaAPIError
+			if !errors.As(wrapped, &apiErr) || apiErr.StatusCode != tt.statusCode {
+				t.Errorf("expected the API error through wrapping, got %v", wrapped)
+			}
+		})
+	}
+}
+
+func TestNewNuvlaAPIErrorFromResponse_Body(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want NuvlaAPIError
+	}{
+		{
+			name: "nuvla error document",
+			body: `{"status": 404, "message": "nuvlabox/1 not found", "resource-id": "nuvlabox/1"}`,
+			want: NuvlaAPIError{StatusCode: 404, Status: 404, Message: "nuvlabox/1 not found", ResourceId: "nuvlabox/1"},
+		},
+		{
+			name: "raw body",
+			body: "upstream unavailable",
+			want: NuvlaAPIError{StatusCode: 404, Message: "upstream unavailable"},
+		},
+		{
+			name: "empty body",
+			want: NuvlaAPIError{StatusCode: 404},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := NewNuvlaAPIErrorFromResponse(newErrorResponse(http.StatusNotFound, tt.body))
+			tt.want.Method = http.MethodGet
+			tt.want.URL = "https://nuvla.io/api/nuvlabox/1"
+			if *apiErr != tt.want {
+				t.Errorf("got %+v, want %+v", *apiErr, tt.want)
+			}
+		})
+	}
+}
+
+func TestResourceNotFoundError_IsNotFound(t *testing.T) {
+	err := fmt.Errorf("searching parameter: %w", NewResourceNotFoundError("deployment-parameter", "ip"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected the wrapped resource error to match ErrNotFound: %s", err)
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Errorf("expected the resource error to match ErrNotFound only: %s", err)
+	}
+	var notFound *ResourceNotFoundError
+	if !errors.As(err, &notFound) || notFound.ResourceID != "ip" {
+		t.Errorf("expected the resource error through wrapping, got %v", err)
+	}
+}
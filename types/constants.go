@@ -3,7 +3,10 @@ package types
 const DefaultEndpoint = "https://nuvla.io"
 const DefaultInsecure = false
 
-// Default path locations
+// Former default path locations, in the shared /tmp directory.
+//
+// Deprecated: the client no longer uses them. The cookies are stored in the user cache directory and the
+// configuration in the user configuration directory, see DefaultCookieFile and DefaultConfigFile of the client.
 const (
 	DefaultConfigPath = "/tmp/.nuvla/"
 
@@ -1,16 +1,40 @@
 package types
 
-import "encoding/json"
+import (
+	"fmt"
+	"github.com/nuvla/api-client-go/common"
+	"log/slog"
+	"sort"
+	"strings"
+)
 
 const (
 	HrefSessionTemplateApiKey   = "session-template/api-key"
 	HrefSessionTemplatePassword = "session-template/password"
+	// HrefSessionTemplateMitreIdToken exchanges a MitreID (OIDC) access token for a session
+	HrefSessionTemplateMitreIdToken = "session-template/mitreid-token"
 )
 
 type LogInParams interface {
 	GetParams() map[string]string
 }
 
+// The login parameters implement fmt.Stringer and slog.LogValuer so that logging them never reveals the secrets
+var (
+	_ slog.LogValuer = (*ApiKeyLogInParams)(nil)
+	_ slog.LogValuer = (*UserLogInParams)(nil)
+	_ slog.LogValuer = (*TemplateLogInParams)(nil)
+	_ slog.LogValuer = (*TokenLogInParams)(nil)
+)
+
+// mask hides a secret, keeping only whether it is set
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return common.Redacted
+}
+
 type ApiKeyLogInParams struct {
 	Key    string `json:"key"`
 	Secret string `json:"secret"`
@@ -35,6 +59,17 @@ func (p *ApiKeyLogInParams) GetParams() map[string]string {
 	return params
 }
 
+func (p *ApiKeyLogInParams) String() string {
+	return fmt.Sprintf("{href: %s, key: %s, secret: %s}", p.Href, p.Key, mask(p.Secret))
+}
+
+func (p *ApiKeyLogInParams) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("href", p.Href),
+		slog.String("key", p.Key),
+		slog.String("secret", mask(p.Secret)))
+}
+
 type UserLogInParams struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -50,12 +85,111 @@ func NewUserLogInParams(username, password string) *UserLogInParams {
 }
 
 func (p *UserLogInParams) GetParams() map[string]string {
-	var params map[string]string
-	jsonParams, _ := json.Marshal(p)
+	href := p.Href
+	if href == "" {
+		href = HrefSessionTemplatePassword
+	}
+	return map[string]string{
+		"href":     href,
+		"username": p.Username,
+		"password": p.Password,
+	}
+}
+
+func (p *UserLogInParams) String() string {
+	return fmt.Sprintf("{href: %s, username: %s, password: %s}", p.Href, p.Username, mask(p.Password))
+}
+
+func (p *UserLogInParams) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("href", p.Href),
+		slog.String("username", p.Username),
+		slog.String("password", mask(p.Password)))
+}
+
+// TemplateLogInParams logs in with any session template. Attributes are the template specific parameters, e.g.
+// "username" and "password" for session-template/password.
+type TemplateLogInParams struct {
+	Href       string            `json:"href"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+func NewTemplateLogInParams(href string, attributes map[string]string) *TemplateLogInParams {
+	return &TemplateLogInParams{
+		Href:       href,
+		Attributes: attributes,
+	}
+}
 
-	err := json.Unmarshal(jsonParams, &params)
-	if err != nil {
-		return nil
+func (p *TemplateLogInParams) GetParams() map[string]string {
+	params := make(map[string]string, len(p.Attributes)+1)
+	for k, v := range p.Attributes {
+		params[k] = v
 	}
+	params["href"] = p.Href
 	return params
 }
+
+// sortedAttributeNames returns the attribute names in a stable order for String and LogValue
+func (p *TemplateLogInParams) sortedAttributeNames() []string {
+	names := make([]string, 0, len(p.Attributes))
+	for k := range p.Attributes {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String masks all the attribute values, as the template decides which of them are secrets
+func (p *TemplateLogInParams) String() string {
+	attributes := make([]string, 0, len(p.Attributes))
+	for _, k := range p.sortedAttributeNames() {
+		attributes = append(attributes, fmt.Sprintf("%s: %s", k, mask(p.Attributes[k])))
+	}
+	return fmt.Sprintf("{href: %s, attributes: {%s}}", p.Href, strings.Join(attributes, ", "))
+}
+
+func (p *TemplateLogInParams) LogValue() slog.Value {
+	attributes := make([]slog.Attr, 0, len(p.Attributes))
+	for _, k := range p.sortedAttributeNames() {
+		attributes = append(attributes, slog.String(k, mask(p.Attributes[k])))
+	}
+	return slog.GroupValue(
+		slog.String("href", p.Href),
+		slog.Attr{Key: "attributes", Value: slog.GroupValue(attributes...)})
+}
+
+// TokenLogInParams exchanges a token issued by an external identity provider for a Nuvla session.
+// Href defaults to session-template/mitreid-token.
+type TokenLogInParams struct {
+	Token string `json:"token"`
+	Href  string `json:"href"`
+}
+
+func NewTokenLogInParams(token string) *TokenLogInParams {
+	return &TokenLogInParams{
+		Token: token,
+		Href:  HrefSessionTemplateMitreIdToken,
+	}
+}
+
+func (p *TokenLogInParams) GetParams() map[string]string {
+	href := p.Href
+	if href == "" {
+		href = HrefSessionTemplateMitreIdToken
+	}
+	return map[string]string{
+		"href":  href,
+		"token": p.Token,
+	}
+}
+
+func (p *TokenLogInParams) String() string {
+	return fmt.Sprintf("{href: %s, token: %s}", p.Href, mask(p.Token))
+}
+
+func (p *TokenLogInParams) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("href", p.Href),
+		slog.String("token", mask(p.Token)))
+}
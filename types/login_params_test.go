@@ -1 +1,122 @@
 package types
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogInParams_MaskSecrets(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  LogInParams
+		secret  string
+		visible []string
+	}{
+		{"api key", NewApiKeyLogInParams("credential/1", "s3cr3t-key"), "s3cr3t-key", []string{"credential/1", HrefSessionTemplateApiKey}},
+		{"user", NewUserLogInParams("jane", "s3cr3t-password"), "s3cr3t-password", []string{"jane", HrefSessionTemplatePassword}},
+		{"template", NewTemplateLogInParams("session-template/custom", map[string]string{"pin": "s3cr3t-pin"}), "s3cr3t-pin", []string{"pin", "session-template/custom"}},
+		{"token", NewTokenLogInParams("s3cr3t-token"), "s3cr3t-token", []string{HrefSessionTemplateMitreIdToken}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			slog.New(slog.NewTextHandler(&buf, nil)).Info("login", "params", tt.params)
+			outputs := map[string]string{
+				"String": fmt.Sprint(tt.params),
+				"%+v":    fmt.Sprintf("%+v", tt.params),
+				"slog":   buf.String(),
+			}
+			for format, out := range outputs {
+				if strings.Contains(out, tt.secret) {
+					t.Errorf("%s output reveals the secret: %s", format, out)
+				}
+				if !strings.Contains(out, "[REDACTED]") {
+					t.Errorf("%s output does not mark the secret as redacted: %s", format, out)
+				}
+				for _, v := range tt.visible {
+					if !strings.Contains(out, v) {
+						t.Errorf("%s output is missing %q: %s", format, v, out)
+					}
+				}
+			}
+			if params := tt.params.GetParams(); !mapContainsValue(params, tt.secret) {
+				t.Errorf("expected the secret in the login request parameters, got %v", params)
+			}
+		})
+	}
+}
+
+func TestLogInParams_GetParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		params LogInParams
+		want   map[string]string
+	}{
+		{
+			name:   "template",
+			params: NewTemplateLogInParams("session-template/custom", map[string]string{"pin": "1234", "user": "jane"}),
+			want:   map[string]string{"href": "session-template/custom", "pin": "1234", "user": "jane"},
+		},
+		{
+			name:   "template href wins over attributes",
+			params: NewTemplateLogInParams("session-template/custom", map[string]string{"href": "session-template/other"}),
+			want:   map[string]string{"href": "session-template/custom"},
+		},
+		{
+			name:   "template without attributes",
+			params: NewTemplateLogInParams("session-template/github-nuvla", nil),
+			want:   map[string]string{"href": "session-template/github-nuvla"},
+		},
+		{
+			name:   "token",
+			params: NewTokenLogInParams("access-token"),
+			want:   map[string]string{"href": HrefSessionTemplateMitreIdToken, "token": "access-token"},
+		},
+		{
+			name:   "token without href",
+			params: &TokenLogInParams{Token: "access-token"},
+			want:   map[string]string{"href": HrefSessionTemplateMitreIdToken, "token": "access-token"},
+		},
+		{
+			name:   "token with custom href",
+			params: &TokenLogInParams{Token: "access-token", Href: "session-template/oidc-nuvla"},
+			want:   map[string]string{"href": "session-template/oidc-nuvla", "token": "access-token"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.params.GetParams()
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTemplateLogInParams_AttributesNotShared(t *testing.T) {
+	attributes := map[string]string{"pin": "1234"}
+	params := NewTemplateLogInParams("session-template/custom", attributes)
+	params.GetParams()["pin"] = "changed"
+	if attributes["pin"] != "1234" || params.Attributes["pin"] != "1234" {
+		t.Errorf("expected GetParams to return a copy of the attributes, got %v", params.Attributes)
+	}
+}
+
+func TestLogInParams_EmptySecret(t *testing.T) {
+	out := NewApiKeyLogInParams("credential/1", "").String()
+	if strings.Contains(out, "[REDACTED]") {
+		t.Errorf("expected an empty secret to be shown as empty, got %s", out)
+	}
+}
+
+func mapContainsValue(m map[string]string, value string) bool {
+	for _, v := range m {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -1,7 +1,7 @@
 package types
 
 import (
-	log "github.com/sirupsen/logrus"
+	"github.com/nuvla/api-client-go/common"
 	"strings"
 )
 
@@ -40,13 +40,13 @@ func NewNuvlaID(uuid string, resourceType string) *NuvlaID {
 func NewNuvlaIDFromId(id string) *NuvlaID {
 	d := strings.Split(id, "/")
 	if id == "" {
-		log.Warnf("Empty Nuvla ID")
+		common.DefaultLogger().Warnf("Empty Nuvla ID")
 		// If empty string, return an empty NuvlaID to prevent NullPointerExceptions
 		return &NuvlaID{}
 	}
 
 	if len(d) != 2 {
-		log.Errorf("Invalid Nuvla ID: %s", id)
+		common.DefaultLogger().Errorf("Invalid Nuvla ID: %s", id)
 		return nil
 	}
 	return &NuvlaID{
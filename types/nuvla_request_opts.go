@@ -1,5 +1,7 @@
 package types
 
+import "time"
+
 type RequestOpts struct {
 	Method   string
 	Endpoint string
@@ -8,6 +10,10 @@ type RequestOpts struct {
 	Params   *RequestParams
 	Headers  map[string]string
 	Bulk     bool
+	// Timeout bounds the whole call, retries included. Zero uses the session timeout.
+	Timeout time.Duration
+	// AuthnHeader overrides the nuvla-authn-info header of the session for this request
+	AuthnHeader string
 }
 
 type RequestParams struct {
@@ -2,7 +2,7 @@ package types
 
 import (
 	"encoding/json"
-	log "github.com/sirupsen/logrus"
+	"github.com/nuvla/api-client-go/common"
 	"io"
 	"net/http"
 )
@@ -18,7 +18,7 @@ func NewResourceFromResponse(resp *http.Response) *NuvlaResource {
 	body, err := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if err != nil {
-		log.Errorf("Error reading response body: %s", err)
+		common.DefaultLogger().Errorf("Error reading response body: %s", err)
 		return nil
 	}
 
@@ -26,10 +26,10 @@ func NewResourceFromResponse(resp *http.Response) *NuvlaResource {
 	var data map[string]interface{}
 	err = json.Unmarshal(body, &data)
 	if err != nil {
-		log.Errorf("Error unmarshaling response body: %s", err)
+		common.DefaultLogger().Errorf("Error unmarshaling response body: %s", err)
 		return nil
 	}
-	log.Debugf("Data received from response: %v", data)
+	common.DefaultLogger().Debugf("Data received from response: %v", data)
 
 	// Return NuvlaResource
 	return &NuvlaResource{
@@ -1,4 +1,117 @@
 package types
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// NuvlaResponse is the standard document returned by Nuvla when adding, editing or deleting resources and when
+// executing operations.
 type NuvlaResponse struct {
+	// HTTP status code of the response
+	StatusCode int `json:"-"`
+
+	Status     int      `json:"status"`
+	Message    string   `json:"message"`
+	ResourceId string   `json:"resource-id"`
+	Location   string   `json:"location"`
+	Jobs       []string `json:"jobs"`
+
+	// Data contains the whole decoded document. Edit returns the updated resource and some operations return
+	// specific fields (e.g. the credentials on NuvlaEdge activation), which are only available here.
+	Data map[string]interface{} `json:"-"`
+}
+
+// NewNuvlaResponseFromResponse decodes the response body into a NuvlaResponse. It consumes and closes the body.
+// An empty body results in an envelope with only the HTTP status code set.
+func NewNuvlaResponseFromResponse(resp *http.Response) (*NuvlaResponse, error) {
+	defer resp.Body.Close()
+
+	res := &NuvlaResponse{
+		StatusCode: resp.StatusCode,
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %w", err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return res, nil
+	}
+
+	if err := json.Unmarshal(body, &res.Data); err != nil {
+		return nil, fmt.Errorf("error decoding response body: %w", err)
+	}
+	res.fillFromData()
+
+	return res, nil
+}
+
+// fillFromData sets the envelope fields from Data. Edit responses are the resource itself, whose fields of the
+// same name can have other types (e.g. the nuvlabox location is a list of coordinates), so they are only set
+// when their type matches.
+func (r *NuvlaResponse) fillFromData() {
+	if status, ok := r.Data["status"].(float64); ok {
+		r.Status = int(status)
+	}
+	if message, ok := r.Data["message"].(string); ok {
+		r.Message = message
+	}
+	if location, ok := r.Data["location"].(string); ok {
+		r.Location = location
+	}
+	if jobs, ok := r.Data["jobs"].([]interface{}); ok {
+		for _, j := range jobs {
+			if job, ok := j.(string); ok {
+				r.Jobs = append(r.Jobs, job)
+			}
+		}
+	}
+
+	if id, ok := r.Data["resource-id"].(string); ok {
+		r.ResourceId = id
+	}
+	// Edit responses are the resource itself, so the id is the resource id
+	if r.ResourceId == "" {
+		if id, ok := r.Data["id"].(string); ok {
+			r.ResourceId = id
+		}
+	}
+}
+
+// GetResourceId returns the ID of the resource the response refers to, or an error if the response does not
+// contain one.
+func (r *NuvlaResponse) GetResourceId() (*NuvlaID, error) {
+	if r.ResourceId == "" {
+		return nil, fmt.Errorf("resource-id not found in response")
+	}
+	id := NewNuvlaIDFromId(r.ResourceId)
+	if id == nil {
+		return nil, &InvalidNuvlaID{Id: r.ResourceId}
+	}
+	return id, nil
+}
+
+// GetJobIds returns the IDs of the jobs created by the request. They come either from the jobs list or from the
+// location of asynchronous operations.
+func (r *NuvlaResponse) GetJobIds() []string {
+	jobs := make([]string, 0, len(r.Jobs)+1)
+	jobs = append(jobs, r.Jobs...)
+	if strings.HasPrefix(r.Location, "job/") && !containsString(jobs, r.Location) {
+		jobs = append(jobs, r.Location)
+	}
+	return jobs
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
 }
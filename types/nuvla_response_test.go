@@ -0,0 +1,50 @@
+package types
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func newTestResponse(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}
+}
+
+func TestNewNuvlaResponseFromResponse(t *testing.T) {
+	res, err := NewNuvlaResponseFromResponse(newTestResponse(http.StatusAccepted,
+		`{"status":202,"message":"starting","resource-id":"deployment/1","location":"job/1","jobs":["job/2"]}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.StatusCode != 202 || res.Status != 202 || res.Message != "starting" || res.ResourceId != "deployment/1" {
+		t.Errorf("unexpected envelope: %+v", res)
+	}
+	if jobs := res.GetJobIds(); len(jobs) != 2 || jobs[0] != "job/2" || jobs[1] != "job/1" {
+		t.Errorf("unexpected jobs: %v", jobs)
+	}
+}
+
+func TestNewNuvlaResponseFromResponse_Resource(t *testing.T) {
+	res, err := NewNuvlaResponseFromResponse(newTestResponse(http.StatusOK,
+		`{"id":"nuvlabox/1","location":[46.2,6.1],"status":"COMMISSIONED","jobs":"none"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.ResourceId != "nuvlabox/1" {
+		t.Errorf("expected resource id nuvlabox/1, got %q", res.ResourceId)
+	}
+	if res.Status != 0 || res.Location != "" || res.Jobs != nil {
+		t.Errorf("expected the resource fields to be ignored, got %+v", res)
+	}
+	if res.Data["status"] != "COMMISSIONED" {
+		t.Errorf("expected the resource in data, got %v", res.Data)
+	}
+}
+
+func TestNewNuvlaResponseFromResponse_EmptyBody(t *testing.T) {
+	res, err := NewNuvlaResponseFromResponse(newTestResponse(http.StatusNoContent, ""))
+	if err != nil || res.StatusCode != http.StatusNoContent {
+		t.Errorf("unexpected response %+v, error %v", res, err)
+	}
+}
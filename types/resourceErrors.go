@@ -16,6 +16,11 @@ func (e ResourceNotFoundError) Error() string {
 	return fmt.Sprintf("%s with ID %s not found", e.ResourceType, e.ResourceID)
 }
 
+// Is allows matching ResourceNotFoundError with ErrNotFound
+func (e ResourceNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
 // NewResourceNotFoundError creates a new ResourceNotFoundError
 func NewResourceNotFoundError(resourceType resources.NuvlaResourceType, resourceID string) *ResourceNotFoundError {
 	return &ResourceNotFoundError{